@@ -0,0 +1,337 @@
+package compliance
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var logger = config.GetLogger()
+
+// Output 合规检查导出文件名，不含扩展名
+const Output = "差旅合规检查"
+
+// 规则条件可以使用的字段
+const (
+	FieldPassenger      = "passenger"
+	FieldDate           = "date"
+	FieldNumber         = "number"
+	FieldSeatCategory   = "seat_category"
+	FieldRawSeat        = "raw_seat_category"
+	FieldTransportClass = "transport_class"
+	FieldCabinClass     = "cabin_class"
+	FieldFare           = "fare"
+	FieldGrade          = "grade"
+	FieldDepartment     = "department"
+	FieldDurationHours  = "duration_hours"
+)
+
+var (
+	providedMu sync.RWMutex
+	// provided 各类文档能够提供的字段，用于在加载规则时发现无法生效的条件
+	provided = make(map[string]bool)
+)
+
+// Provide 声明文档能够提供的字段，由实现了 Subject 的文档包在 init 中调用；
+// 依赖外部数据的字段（如依赖时刻表的 duration_hours）在数据加载后声明
+func Provide(fields ...string) {
+	providedMu.Lock()
+	defer providedMu.Unlock()
+	for _, field := range fields {
+		provided[field] = true
+	}
+}
+
+// UnavailableFields 返回规则引用、但没有任何文档提供的字段。
+// 字段值未知时条件不成立，引用这些字段的规则永远不会触发
+func (rs *Rules) UnavailableFields() []string {
+	providedMu.RLock()
+	defer providedMu.RUnlock()
+
+	fields := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, rule := range rs.Rules {
+		for _, c := range rule.When {
+			if !provided[c.Field] && !seen[c.Field] {
+				seen[c.Field] = true
+				fields = append(fields, c.Field)
+			}
+		}
+	}
+	return fields
+}
+
+// Facts 文档中可供规则判断的字段值，值为空表示未知
+type Facts map[string]string
+
+// Condition 规则条件，如 {field: fare, op: gt, value: 1000}
+type Condition struct {
+	Field string `yaml:"field"`
+	Op    string `yaml:"op"`
+	// Value 为单个值，In/NotIn 时使用 Values
+	Value  string   `yaml:"value"`
+	Values []string `yaml:"values"`
+}
+
+// Rule 合规规则，When 中的条件全部满足时判定为违规
+type Rule struct {
+	Name    string      `yaml:"name"`
+	Message string      `yaml:"message"`
+	When    []Condition `yaml:"when"`
+}
+
+// Rules 一组合规规则
+type Rules struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Violation 一条违规记录
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// LoadRules 读取规则文件
+func LoadRules(path string) (*Rules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compliance rules: %w", err)
+	}
+	return ParseRules(content)
+}
+
+// ParseRules 解析并校验规则
+func ParseRules(content []byte) (*Rules, error) {
+	var rules Rules
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse compliance rules: %w", err)
+	}
+
+	for i, rule := range rules.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if len(rule.When) == 0 {
+			return nil, fmt.Errorf("rule %s has no conditions", rule.Name)
+		}
+		for _, c := range rule.When {
+			if c.Field == "" {
+				return nil, fmt.Errorf("rule %s: condition without field", rule.Name)
+			}
+			switch c.Op {
+			case "eq", "ne", "lt", "le", "gt", "ge", "contains":
+			case "in", "not_in":
+				if len(c.Values) == 0 {
+					return nil, fmt.Errorf("rule %s: %s requires values", rule.Name, c.Op)
+				}
+			default:
+				return nil, fmt.Errorf("rule %s: unknown op %q", rule.Name, c.Op)
+			}
+		}
+		if rule.Message == "" {
+			rules.Rules[i].Message = rule.Name
+		}
+	}
+	return &rules, nil
+}
+
+// Evaluate 返回事实违反的规则
+func (rs *Rules) Evaluate(facts Facts) []Violation {
+	violations := make([]Violation, 0)
+	for _, rule := range rs.Rules {
+		matched := true
+		for _, c := range rule.When {
+			if !c.matches(facts) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			violations = append(violations, Violation{Rule: rule.Name, Message: rule.Message})
+		}
+	}
+	return violations
+}
+
+// matches 判断条件是否成立。字段值未知时条件不成立，避免因数据缺失误报
+func (c Condition) matches(facts Facts) bool {
+	value, ok := facts[c.Field]
+	if !ok || value == "" {
+		return false
+	}
+
+	switch c.Op {
+	case "eq":
+		return value == c.Value
+	case "ne":
+		return value != c.Value
+	case "contains":
+		return strings.Contains(value, c.Value)
+	case "in":
+		return contains(c.Values, value)
+	case "not_in":
+		return !contains(c.Values, value)
+	}
+
+	cmp, ok := compare(value, c.Value)
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+var gradePattern = regexp.MustCompile(`^(\D*)(\d+(?:\.\d+)?)$`)
+
+// compare 比较两个值：均为数字时按数值比较；
+// 形如"P5"、"M2"的职级在前缀相同时按数字部分比较
+func compare(a, b string) (int, bool) {
+	matchA := gradePattern.FindStringSubmatch(strings.TrimSpace(a))
+	matchB := gradePattern.FindStringSubmatch(strings.TrimSpace(b))
+	if matchA == nil || matchB == nil || matchA[1] != matchB[1] {
+		return 0, false
+	}
+
+	x, errA := strconv.ParseFloat(matchA[2], 64)
+	y, errB := strconv.ParseFloat(matchB[2], 64)
+	if errA != nil || errB != nil {
+		return 0, false
+	}
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// Flags 记录文档的合规检查结果，嵌入到需要检查的文档中
+type Flags struct {
+	violations []Violation
+}
+
+// SetViolations 记录违规
+func (f *Flags) SetViolations(violations []Violation) {
+	f.violations = violations
+}
+
+// Violations 返回违规记录
+func (f *Flags) Violations() []Violation {
+	return f.violations
+}
+
+// ViolationSummary 将违规记录拼接为一个单元格的文字，无违规时返回空字符串
+func (f *Flags) ViolationSummary() string {
+	messages := make([]string, 0, len(f.violations))
+	for _, v := range f.violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "；")
+}
+
+// Subject 可以进行合规检查的文档
+type Subject interface {
+	Facts() Facts
+	SetViolations(violations []Violation)
+	Violations() []Violation
+}
+
+// Check 对所有可检查的文档执行规则检查，并在文档上记录违规
+func (rs *Rules) Check(docs []doctype.Document) int {
+	count := 0
+	for _, doc := range docs {
+		subject, ok := doc.(Subject)
+		if !ok {
+			continue
+		}
+		violations := rs.Evaluate(subject.Facts())
+		subject.SetViolations(violations)
+		count += len(violations)
+	}
+	return count
+}
+
+// Report 汇总文档上的违规记录，导出合规检查表
+type Report struct {
+	rows []reportRow
+}
+
+type reportRow struct {
+	facts     Facts
+	violation Violation
+}
+
+func (r *Report) Add(doc doctype.Document) {
+	subject, ok := doc.(Subject)
+	if !ok {
+		return
+	}
+	for _, v := range subject.Violations() {
+		r.rows = append(r.rows, reportRow{facts: subject.Facts(), violation: v})
+	}
+}
+
+var reportColumns = []doctype.Column{
+	{Key: "passenger", Header: "人员"},
+	{Key: "department", Header: "部门"},
+	{Key: "grade", Header: "职级"},
+	{Key: "date", Header: "日期", Type: doctype.ColumnDate},
+	{Key: "number", Header: "车次/航班"},
+	{Key: "seat_category", Header: "座席/舱位"},
+	{Key: "fare", Header: "票价"},
+	{Key: "rule", Header: "违反规则"},
+	{Key: "message", Header: "说明"},
+}
+
+func (r *Report) Sheets() []doctype.Sheet {
+	if len(r.rows) == 0 {
+		return nil
+	}
+
+	rows := make([][]interface{}, 0, len(r.rows))
+	for _, row := range r.rows {
+		seat := row.facts[FieldSeatCategory]
+		if seat == "" {
+			seat = row.facts[FieldCabinClass]
+		}
+		rows = append(rows, []interface{}{
+			row.facts[FieldPassenger],
+			row.facts[FieldDepartment],
+			row.facts[FieldGrade],
+			row.facts[FieldDate],
+			row.facts[FieldNumber],
+			seat,
+			row.facts[FieldFare],
+			row.violation.Rule,
+			row.violation.Message,
+		})
+	}
+
+	return []doctype.Sheet{{Name: "合规检查", Columns: reportColumns, Rows: rows}}
+}
@@ -0,0 +1,69 @@
+package compliance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const rulesFile = `
+rules:
+  - name: 低职级限乘二等座
+    message: P6 以下员工只能乘坐二等座
+    when:
+      - {field: grade, op: lt, value: P6}
+      - {field: raw_seat_category, op: not_in, values: [二等座, 无座, 硬座, 硬卧]}
+  - name: 短途禁止一等座
+    when:
+      - {field: raw_seat_category, op: in, values: [一等座, 商务座]}
+      - {field: duration_hours, op: lt, value: 4}
+  - name: 票价上限
+    when:
+      - {field: fare, op: gt, value: 1500}
+`
+
+func TestEvaluate(t *testing.T) {
+	rules, err := ParseRules([]byte(rulesFile))
+	require.NoError(t, err)
+
+	violations := rules.Evaluate(Facts{
+		FieldGrade: "P5", FieldRawSeat: "一等座", FieldDurationHours: "2.5", FieldFare: "933",
+	})
+	require.Len(t, violations, 2)
+	assert.Equal(t, "P6 以下员工只能乘坐二等座", violations[0].Message)
+	// 未填写说明的规则使用规则名称
+	assert.Equal(t, "短途禁止一等座", violations[1].Message)
+
+	// 职级满足、运行时长未知时不判定违规
+	assert.Empty(t, rules.Evaluate(Facts{FieldGrade: "P7", FieldRawSeat: "一等座", FieldFare: "933"}))
+
+	// 职级前缀不同时无法比较
+	assert.Empty(t, rules.Evaluate(Facts{FieldGrade: "M1", FieldRawSeat: "商务座"}))
+
+	violations = rules.Evaluate(Facts{FieldGrade: "P8", FieldRawSeat: "商务座", FieldFare: "1748"})
+	require.Len(t, violations, 1)
+	assert.Equal(t, "票价上限", violations[0].Rule)
+}
+
+func TestParseRulesRejectsUnknownOp(t *testing.T) {
+	_, err := ParseRules([]byte(`rules: [{name: x, when: [{field: fare, op: between, value: 1}]}]`))
+	assert.Error(t, err)
+}
+
+func TestUnavailableFields(t *testing.T) {
+	rules, err := ParseRules([]byte(rulesFile + `
+  - name: 限乘经济舱
+    when:
+      - {field: cabin_class, op: ne, value: 经济舱}
+      - {field: grade, op: lt, value: P8}
+`))
+	require.NoError(t, err)
+
+	Provide(FieldGrade, FieldRawSeat, FieldFare)
+	assert.Equal(t, []string{FieldDurationHours, FieldCabinClass}, rules.UnavailableFields())
+
+	// 加载时刻表、注册机票处理器后不再提示
+	Provide(FieldDurationHours, FieldCabinClass)
+	assert.Empty(t, rules.UnavailableFields())
+}
@@ -0,0 +1,120 @@
+package dedup
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/store"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var logger = config.GetLogger()
+
+// Policy 发现重复票据时的处理方式
+type Policy string
+
+const (
+	// PolicyFlag 保留重复票据，在导出中标记
+	PolicyFlag Policy = "flag"
+	// PolicySkip 不导出重复票据
+	PolicySkip Policy = "skip"
+)
+
+// ParsePolicy 解析处理方式，为空时标记重复
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(strings.ToLower(strings.TrimSpace(s))) {
+	case "", PolicyFlag:
+		return PolicyFlag, nil
+	case PolicySkip:
+		return PolicySkip, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q, expected flag or skip", s)
+	}
+}
+
+// KeyStore 持久化的票据键登记表，由 store.Store 实现
+type KeyStore interface {
+	FindKey(docType doctype.DocumentType, key string) (*store.SeenKey, error)
+}
+
+// Duplicate 一张重复的票据及其首次出现的位置
+type Duplicate struct {
+	SourcePath string
+	DocType    doctype.DocumentType
+	Key        string
+	// FirstRunID 首次出现的运行，同一次运行中重复时为当前运行
+	FirstRunID      string
+	FirstSourcePath string
+	FirstSeenAt     time.Time
+}
+
+// Note 重复说明，写入导出的"重复"列
+func (d *Duplicate) Note() string {
+	if d.FirstRunID == "" {
+		return fmt.Sprintf("与本次运行的 %s 重复", d.FirstSourcePath)
+	}
+	return fmt.Sprintf("与运行 %s 的 %s 重复", d.FirstRunID, d.FirstSourcePath)
+}
+
+// Detector 按票据类型与键判断重复，同时检查本次运行中已处理的票据与历史运行登记的键
+type Detector struct {
+	// Keys 历史运行的票据键，为 nil 时只检查本次运行
+	Keys KeyStore
+	// RunID 本次运行的 ID，未保存处理记录时为空
+	RunID string
+	// Duplicates 本次运行发现的重复票据
+	Duplicates []Duplicate
+	// Reprocessed 历史运行中处理过的同一文件，重新处理时不视为重复
+	Reprocessed []string
+	seen        map[string]Duplicate
+}
+
+// Check 判断文档是否重复，重复时返回首次出现的位置，否则登记到本次运行中。
+// 历史运行中键相同且源文件内容相同（sourceHash 一致）时是对同一文件的重新处理，不视为重复。
+// 没有实现 doctype.Keyed 或键为空的文档不做判断。
+func (d *Detector) Check(doc doctype.Document, sourcePath, sourceHash string) (*Duplicate, error) {
+	keyed, ok := doc.(doctype.Keyed)
+	if !ok {
+		return nil, nil
+	}
+	key := keyed.DocumentKey()
+	if key == "" {
+		return nil, nil
+	}
+
+	docType := doc.DocumentType()
+	seenKey := string(docType) + "\x00" + key
+	if d.seen == nil {
+		d.seen = make(map[string]Duplicate)
+	}
+
+	first, exists := d.seen[seenKey]
+	if !exists && d.Keys != nil {
+		seen, err := d.Keys.FindKey(docType, key)
+		if err != nil {
+			return nil, err
+		}
+		if seen != nil && sourceHash != "" && seen.SourceHash == sourceHash {
+			logger.Infof("%s was already processed in run %s, not a duplicate", sourcePath, seen.RunID)
+			d.Reprocessed = append(d.Reprocessed, sourcePath)
+		} else if seen != nil {
+			first = Duplicate{FirstRunID: seen.RunID, FirstSourcePath: seen.SourcePath, FirstSeenAt: seen.SeenAt}
+			exists = true
+		}
+	}
+	if !exists {
+		d.seen[seenKey] = Duplicate{FirstRunID: d.RunID, FirstSourcePath: sourcePath, FirstSeenAt: time.Now()}
+		return nil, nil
+	}
+
+	duplicate := first
+	duplicate.SourcePath = sourcePath
+	duplicate.DocType = docType
+	duplicate.Key = key
+	d.Duplicates = append(d.Duplicates, duplicate)
+
+	logger.Warnf("%s duplicates %s (%s) first seen in run %s: %s",
+		sourcePath, key, docType, duplicate.FirstRunID, duplicate.FirstSourcePath)
+	return &duplicate, nil
+}
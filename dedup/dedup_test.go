@@ -0,0 +1,238 @@
+package dedup
+
+import (
+	"FinDocOCR/doctype"
+	"FinDocOCR/store"
+	"FinDocOCR/utils"
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDoc struct {
+	Number string
+	doctype.DuplicateMark
+}
+
+func (d *testDoc) DocumentType() doctype.DocumentType { return doctype.TypeVatInvoice }
+func (d *testDoc) AmendData()                         {}
+func (d *testDoc) DocumentKey() string                { return d.Number }
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyFlag, policy)
+
+	policy, err = ParsePolicy("SKIP")
+	require.NoError(t, err)
+	assert.Equal(t, PolicySkip, policy)
+
+	_, err = ParsePolicy("drop")
+	assert.Error(t, err)
+}
+
+func TestDetectorWithinRun(t *testing.T) {
+	d := &Detector{RunID: "run-1"}
+
+	dup, err := d.Check(&testDoc{Number: "001"}, "a.jpg", "aaa")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+
+	// 键为空时无法判断
+	dup, err = d.Check(&testDoc{}, "b.jpg", "bbb")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+
+	// 同一次运行中即使文件内容相同也是重复提交
+	dup, err = d.Check(&testDoc{Number: "001"}, "c.jpg", "aaa")
+	require.NoError(t, err)
+	require.NotNil(t, dup)
+	assert.Equal(t, "run-1", dup.FirstRunID)
+	assert.Equal(t, "a.jpg", dup.FirstSourcePath)
+	assert.Equal(t, "c.jpg", dup.SourcePath)
+	assert.Len(t, d.Duplicates, 1)
+}
+
+func TestDetectorAcrossRuns(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "store.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	first, err := s.StartRun("docs")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveRecords(first, []*store.Record{
+		{SourcePath: "docs/a.jpg", SourceHash: "aaa", Provider: "baidu", Document: &testDoc{Number: "001"}},
+	}))
+
+	second, err := s.StartRun("docs")
+	require.NoError(t, err)
+	d := &Detector{Keys: s, RunID: second.ID}
+
+	dup, err := d.Check(&testDoc{Number: "001"}, "docs/scan.jpg", "scan")
+	require.NoError(t, err)
+	require.NotNil(t, dup)
+	assert.Equal(t, first.ID, dup.FirstRunID)
+	assert.Equal(t, "docs/a.jpg", dup.FirstSourcePath)
+
+	dup, err = d.Check(&testDoc{Number: "002"}, "docs/b.jpg", "bbb")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+	assert.Len(t, d.Duplicates, 1)
+}
+
+func TestDetectorRerunSameFiles(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "store.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	first, err := s.StartRun("docs")
+	require.NoError(t, err)
+	require.NoError(t, s.SaveRecords(first, []*store.Record{
+		{SourcePath: "docs/a.jpg", SourceHash: "aaa", Provider: "baidu", Document: &testDoc{Number: "001"}},
+	}))
+
+	// 对同一目录再次运行时，内容相同的文件是重新处理而不是重复报销
+	second, err := s.StartRun("docs")
+	require.NoError(t, err)
+	d := &Detector{Keys: s, RunID: second.ID}
+	dup, err := d.Check(&testDoc{Number: "001"}, "docs/a.jpg", "aaa")
+	require.NoError(t, err)
+	assert.Nil(t, dup)
+	assert.Equal(t, []string{"docs/a.jpg"}, d.Reprocessed)
+	assert.Empty(t, d.Duplicates)
+
+	// 本次运行中再次出现的同一票据仍是重复
+	dup, err = d.Check(&testDoc{Number: "001"}, "docs/copy/a.jpg", "copy")
+	require.NoError(t, err)
+	require.NotNil(t, dup)
+	assert.Equal(t, second.ID, dup.FirstRunID)
+	assert.Equal(t, "docs/a.jpg", dup.FirstSourcePath)
+}
+
+func TestExcludeDuplicates(t *testing.T) {
+	duplicate := &testDoc{Number: "001"}
+	duplicate.MarkDuplicate("与本次运行的 a.jpg 重复")
+	similar := &testDoc{Number: "002"}
+	similar.MarkSimilar("与 a.jpg 图像相似，请核对")
+	unique := &testDoc{Number: "003"}
+
+	// 只有确认重复的票据不计入汇总，图像相似的票据可能是版式相同的不同票据
+	docs := doctype.ExcludeDuplicates([]doctype.Document{duplicate, similar, unique})
+	assert.Equal(t, []doctype.Document{similar, unique}, docs)
+	assert.NotEmpty(t, similar.DuplicateNote())
+}
+
+func TestImageGrouper(t *testing.T) {
+	g := &ImageGrouper{Threshold: DefaultImageThreshold}
+
+	representative, skip, similar := g.Add("photo.jpg", "sha-photo", 0xFF00)
+	assert.False(t, skip)
+	assert.False(t, similar)
+	assert.Equal(t, "photo.jpg", representative)
+
+	// 相差 2 位，视为相似，但内容不同仍需识别
+	representative, skip, similar = g.Add("scan.png", "sha-scan", 0xFF03)
+	assert.False(t, skip)
+	assert.True(t, similar)
+	assert.Equal(t, "photo.jpg", representative)
+
+	// 文件内容相同时不再识别
+	representative, skip, _ = g.Add("copy/photo.jpg", "sha-photo", 0xFF00)
+	assert.True(t, skip)
+	assert.Equal(t, "photo.jpg", representative)
+
+	_, skip, similar = g.Add("other.jpg", "sha-other", 0x00FF)
+	assert.False(t, skip)
+	assert.False(t, similar)
+
+	groups := g.Groups()
+	require.Len(t, groups, 1)
+	assert.Equal(t, "photo.jpg", groups[0].Representative)
+	assert.Equal(t, []ImageMember{
+		{SourcePath: "scan.png", Distance: 2},
+		{SourcePath: "copy/photo.jpg", Identical: true, Skipped: true},
+	}, groups[0].Members)
+
+	// 阈值小于 0 时不分组
+	disabled := &ImageGrouper{Threshold: -1}
+	disabled.Add("photo.jpg", "sha-photo", 0xFF00)
+	_, skip, _ = disabled.Add("copy.jpg", "sha-photo", 0xFF00)
+	assert.False(t, skip)
+}
+
+// ticketImage 生成一张模拟车票：相同的版式（底色、表格线、标题栏），票号区域的数字不同
+func ticketImage(t *testing.T, digits []int) []byte {
+	img := image.NewGray(image.Rect(0, 0, 900, 560))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.Gray{Y: 235}}, image.Point{}, draw.Src)
+	fill := func(r image.Rectangle, y uint8) {
+		draw.Draw(img, r, &image.Uniform{C: color.Gray{Y: y}}, image.Point{}, draw.Src)
+	}
+	fill(image.Rect(0, 0, 900, 80), 60)
+	for y := 140; y < 560; y += 70 {
+		fill(image.Rect(40, y, 860, y+3), 90)
+	}
+	fill(image.Rect(40, 100, 43, 520), 90)
+	fill(image.Rect(857, 100, 860, 520), 90)
+	// 票号、金额等文字用细小的笔画表示
+	for i, digit := range digits {
+		x := 80 + i*28
+		for stroke := 0; stroke <= digit; stroke++ {
+			fill(image.Rect(x+stroke*2, 160, x+stroke*2+1, 190), 30)
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageGrouperSkipThreshold(t *testing.T) {
+	// 设置后距离不超过 SkipThreshold 的相似图像也跳过识别，每组只识别一张
+	g := &ImageGrouper{Threshold: DefaultImageThreshold, SkipThreshold: 2}
+	g.Add("photo.jpg", "sha-photo", 0xFF00)
+
+	representative, skip, similar := g.Add("scan.png", "sha-scan", 0xFF03)
+	assert.True(t, skip)
+	assert.True(t, similar)
+	assert.Equal(t, "photo.jpg", representative)
+
+	// 超过 SkipThreshold 但不超过 Threshold 的图像仍提交识别
+	_, skip, similar = g.Add("crop.png", "sha-crop", 0xFF0F)
+	assert.False(t, skip)
+	assert.True(t, similar)
+
+	groups := g.Groups()
+	require.Len(t, groups, 1)
+	assert.Equal(t, []ImageMember{
+		{SourcePath: "scan.png", Distance: 2, Skipped: true},
+		{SourcePath: "crop.png", Distance: 4},
+	}, groups[0].Members)
+}
+
+func TestImageGrouperKeepsDistinctTicketsWithSameTemplate(t *testing.T) {
+	first := ticketImage(t, []int{1, 2, 3, 4, 5, 6, 7, 8})
+	second := ticketImage(t, []int{8, 7, 6, 5, 4, 3, 2, 1})
+	require.NotEqual(t, first, second)
+
+	firstHash, err := utils.DHash(first)
+	require.NoError(t, err)
+	secondHash, err := utils.DHash(second)
+	require.NoError(t, err)
+	// 版式相同的两张票据感知哈希几乎相同，无法据此判断是否为同一票据
+	require.LessOrEqual(t, utils.HammingDistance(firstHash, secondHash), DefaultImageThreshold)
+
+	g := &ImageGrouper{Threshold: DefaultImageThreshold}
+	_, skip, _ := g.Add("ticket-1.png", "sha-1", firstHash)
+	assert.False(t, skip)
+	representative, skip, similar := g.Add("ticket-2.png", "sha-2", secondHash)
+	assert.False(t, skip, "distinct ticket must still be submitted for OCR")
+	assert.True(t, similar)
+	assert.Equal(t, "ticket-1.png", representative)
+}
@@ -0,0 +1,95 @@
+package dedup
+
+import "FinDocOCR/utils"
+
+// DefaultImageThreshold 默认的相似图像判定阈值，即 64 位 dHash 间允许的最大汉明距离
+const DefaultImageThreshold = 5
+
+// ImageMember 相似图像组中的一张图像
+type ImageMember struct {
+	SourcePath string
+	// Distance 与代表图像的汉明距离
+	Distance int
+	// Identical 与组内已有文件的内容完全相同，未提交识别
+	Identical bool
+	// Skipped 未提交识别，内容相同或距离不超过 SkipThreshold
+	Skipped bool
+}
+
+// ImageGroup 内容相近的一组图像
+type ImageGroup struct {
+	Representative string
+	hash           uint64
+	Members        []ImageMember
+}
+
+// ImageGrouper 在识别前按文件哈希与感知哈希对图像分组。
+// 默认只有文件内容完全相同的副本才跳过识别；感知哈希相近的图像可能是同一票据的照片与扫描件，
+// 也可能是版式相同的不同票据（如同一车站的车票、同一商户的定额发票），9×8 的 dHash 无法区分，
+// 因此默认仍然提交识别，只在运行报告中列出，由重复报销检查按票号判断是否重复。
+// 确认票据目录中没有同版式的不同票据时，可以设置 SkipThreshold 跳过相似图像以节省识别额度
+type ImageGrouper struct {
+	// Threshold 汉明距离不超过该值的图像视为相似，小于 0 时不分组
+	Threshold int
+	// SkipThreshold 大于 0 时，与代表图像的汉明距离不超过该值的相似图像也跳过识别；
+	// 为 0 时只跳过内容相同的文件。超过 Threshold 的部分不起作用
+	SkipThreshold int
+	groups        []*ImageGroup
+	// bySource 文件哈希与所在组
+	bySource map[string]*ImageGroup
+}
+
+// Add 将图像加入分组，返回所在组的代表图像。与已有图像相似时 similar 为 true；
+// 与已有文件内容完全相同，或相似且距离不超过 SkipThreshold 时 skip 为 true，不需要再提交识别，
+// 其余相似图像需要提交识别并提示复核
+func (g *ImageGrouper) Add(sourcePath, sourceHash string, hash uint64) (representative string, skip, similar bool) {
+	if g.Threshold < 0 {
+		return sourcePath, false, false
+	}
+	if g.bySource == nil {
+		g.bySource = make(map[string]*ImageGroup)
+	}
+
+	if group, exists := g.bySource[sourceHash]; exists {
+		group.Members = append(group.Members, ImageMember{SourcePath: sourcePath, Identical: true, Skipped: true})
+		logger.Warnf("%s is identical to %s, skipped OCR", sourcePath, group.Representative)
+		return group.Representative, true, false
+	}
+
+	// 选择距离最近的组
+	var nearest *ImageGroup
+	nearestDistance := g.Threshold + 1
+	for _, group := range g.groups {
+		if distance := utils.HammingDistance(group.hash, hash); distance < nearestDistance {
+			nearest, nearestDistance = group, distance
+		}
+	}
+
+	if nearest == nil {
+		group := &ImageGroup{Representative: sourcePath, hash: hash}
+		g.groups = append(g.groups, group)
+		g.bySource[sourceHash] = group
+		return sourcePath, false, false
+	}
+
+	skip = g.SkipThreshold > 0 && nearestDistance <= g.SkipThreshold
+	nearest.Members = append(nearest.Members, ImageMember{SourcePath: sourcePath, Distance: nearestDistance, Skipped: skip})
+	g.bySource[sourceHash] = nearest
+	if skip {
+		logger.Warnf("%s looks similar to %s (distance %d), skipped OCR", sourcePath, nearest.Representative, nearestDistance)
+		return nearest.Representative, true, true
+	}
+	logger.Warnf("%s looks similar to %s (distance %d), submitted for OCR", sourcePath, nearest.Representative, nearestDistance)
+	return nearest.Representative, false, true
+}
+
+// Groups 返回包含相同或相似图像的组，按代表图像的加入顺序排列
+func (g *ImageGrouper) Groups() []ImageGroup {
+	groups := make([]ImageGroup, 0)
+	for _, group := range g.groups {
+		if len(group.Members) > 0 {
+			groups = append(groups, *group)
+		}
+	}
+	return groups
+}
@@ -26,11 +26,14 @@ const (
 )
 
 type Document interface {
+	// DocumentType 返回文档的识别类型，用于在注册表中查找所属分组
+	DocumentType() DocumentType
 	AmendData()
 }
 
 // DocumentCollection 定义文档集合接口
 type DocumentCollection interface {
 	Add(doc Document)
-	SaveToFile() error
+	// Sheets 返回待导出的表格，具体的文件格式由导出器决定
+	Sheets() []Sheet
 }
@@ -0,0 +1,62 @@
+package doctype
+
+// Keyed 可以判断是否重复报销的文档。
+// DocumentKey 返回在同类文档中唯一标识该票据的键，识别结果不足以确定时返回空字符串。
+type Keyed interface {
+	DocumentKey() string
+}
+
+// DuplicateMarker 可以在导出中标记为重复的文档
+type DuplicateMarker interface {
+	MarkDuplicate(note string)
+	MarkSimilar(note string)
+}
+
+// Duplicated 可以判断是否已确认为重复报销的文档
+type Duplicated interface {
+	IsDuplicate() bool
+}
+
+// DuplicateMark 记录文档与已处理过的票据重复，嵌入到需要标记重复的文档中
+type DuplicateMark struct {
+	duplicateNote string
+	duplicate     bool
+}
+
+// MarkDuplicate 标记为重复报销，note 说明首次出现的位置
+func (m *DuplicateMark) MarkDuplicate(note string) {
+	m.duplicateNote = note
+	m.duplicate = true
+}
+
+// MarkSimilar 标记为疑似重复，如图像与其他票据相似但票据键不同，只提示复核
+func (m *DuplicateMark) MarkSimilar(note string) {
+	m.duplicateNote = note
+}
+
+// DuplicateNote 返回重复说明，未重复时返回空字符串
+func (m *DuplicateMark) DuplicateNote() string {
+	return m.duplicateNote
+}
+
+// IsDuplicate 是否已确认为重复报销，疑似重复时返回 false
+func (m *DuplicateMark) IsDuplicate() bool {
+	return m.duplicate
+}
+
+// IsDuplicate 判断文档是否已确认为重复报销
+func IsDuplicate(doc Document) bool {
+	duplicated, ok := doc.(Duplicated)
+	return ok && duplicated.IsDuplicate()
+}
+
+// ExcludeDuplicates 返回去除已确认重复报销的文档后的列表，用于抵扣、行程、合规等汇总，避免重复计算
+func ExcludeDuplicates(docs []Document) []Document {
+	result := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		if !IsDuplicate(doc) {
+			result = append(result, doc)
+		}
+	}
+	return result
+}
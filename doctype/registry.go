@@ -0,0 +1,123 @@
+package doctype
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Processor 将百度返回的识别结果解析为文档
+type Processor interface {
+	Process(data []byte) (Document, error)
+}
+
+// Registration 描述一种文档类型：识别类型、处理器、集合构造函数以及导出信息。
+// 各文档包在 init 中调用 Register 完成注册，新增类型时无需修改其他代码。
+type Registration struct {
+	Type DocumentType
+	// Name 文档类型的中文名称，用于日志与报表
+	Name      string
+	Processor Processor
+	// Group 导出分组，同组的类型共用一个集合并导出到同一个文件，为空时使用 Type
+	Group         string
+	NewCollection func() DocumentCollection
+	// Output 导出文件名（不含扩展名），同组的类型应使用相同的文件名
+	Output string
+	// Fallback 为 true 时，作为未知类型的兜底处理
+	Fallback bool
+	// Reconcile 可选，在文档加入集合之前调用，用于关联同类文档（例如退票与原车票）
+	Reconcile func(docs []Document)
+}
+
+var (
+	registryMu    sync.RWMutex
+	registrations = make(map[DocumentType]Registration)
+	registryOrder []DocumentType
+	fallbackType  DocumentType
+)
+
+// Register 注册一种文档类型。重复注册或缺少必要字段时 panic，
+// 这类错误只会出现在开发阶段。
+func Register(r Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if r.Type == "" {
+		panic("doctype: Register with empty Type")
+	}
+	if r.Processor == nil || r.NewCollection == nil {
+		panic(fmt.Sprintf("doctype: Register %s without Processor or NewCollection", r.Type))
+	}
+	if _, dup := registrations[r.Type]; dup {
+		panic(fmt.Sprintf("doctype: Register called twice for %s", r.Type))
+	}
+	if r.Group == "" {
+		r.Group = string(r.Type)
+	}
+	if r.Fallback {
+		if fallbackType != "" {
+			panic(fmt.Sprintf("doctype: fallback already registered as %s", fallbackType))
+		}
+		fallbackType = r.Type
+	}
+
+	registrations[r.Type] = r
+	registryOrder = append(registryOrder, r.Type)
+}
+
+// Override 以新的注册信息替换已注册的类型，保留其导出顺序，用于以配置文件覆盖内置类型。
+// 兜底类型不能被替换。
+func Override(r Registration) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registrations[r.Type]; !exists {
+		return fmt.Errorf("document type %s is not registered", r.Type)
+	}
+	if r.Type == fallbackType || r.Fallback {
+		return fmt.Errorf("fallback type %s cannot be overridden", r.Type)
+	}
+	if r.Processor == nil || r.NewCollection == nil {
+		return fmt.Errorf("override %s without Processor or NewCollection", r.Type)
+	}
+	if r.Group == "" {
+		r.Group = string(r.Type)
+	}
+
+	registrations[r.Type] = r
+	return nil
+}
+
+// Lookup 返回指定类型的注册信息
+func Lookup(t DocumentType) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registrations[t]
+	return r, ok
+}
+
+// Resolve 返回指定类型的注册信息，未注册的类型返回兜底注册信息
+func Resolve(t DocumentType) (Registration, error) {
+	if r, ok := Lookup(t); ok {
+		return r, nil
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if fallbackType == "" {
+		return Registration{}, fmt.Errorf("unsupported document type: %s", t)
+	}
+	return registrations[fallbackType], nil
+}
+
+// Registrations 按注册顺序返回所有注册信息
+func Registrations() []Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	result := make([]Registration, 0, len(registryOrder))
+	for _, t := range registryOrder {
+		result = append(result, registrations[t])
+	}
+	return result
+}
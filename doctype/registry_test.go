@@ -0,0 +1,124 @@
+package doctype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubProcessor struct{}
+
+func (p *stubProcessor) Process(data []byte) (Document, error) { return nil, nil }
+
+type stubCollection struct{}
+
+func (c *stubCollection) Add(doc Document) {}
+func (c *stubCollection) Sheets() []Sheet  { return nil }
+
+func stubRegistration(t DocumentType) Registration {
+	return Registration{
+		Type:          t,
+		Processor:     &stubProcessor{},
+		NewCollection: func() DocumentCollection { return &stubCollection{} },
+	}
+}
+
+// withCleanRegistry 测试期间使用空的注册表，结束后恢复
+func withCleanRegistry(t *testing.T) {
+	saved, savedOrder, savedFallback := registrations, registryOrder, fallbackType
+	registrations = make(map[DocumentType]Registration)
+	registryOrder = nil
+	fallbackType = ""
+	t.Cleanup(func() {
+		registrations, registryOrder, fallbackType = saved, savedOrder, savedFallback
+	})
+}
+
+func TestRegisterAndLookup(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(stubRegistration("stub_a"))
+	r := stubRegistration("stub_b")
+	r.Group = "stub_group"
+	Register(r)
+
+	got, ok := Lookup("stub_a")
+	require.True(t, ok)
+	assert.Equal(t, DocumentType("stub_a"), got.Type)
+	// 未设置分组时以类型作为分组
+	assert.Equal(t, "stub_a", got.Group)
+
+	got, ok = Lookup("stub_b")
+	require.True(t, ok)
+	assert.Equal(t, "stub_group", got.Group)
+
+	all := Registrations()
+	require.Len(t, all, 2)
+	assert.Equal(t, DocumentType("stub_a"), all[0].Type)
+	assert.Equal(t, DocumentType("stub_b"), all[1].Type)
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(stubRegistration("stub_a"))
+	assert.Panics(t, func() { Register(stubRegistration("stub_a")) }, "duplicate type")
+	assert.Panics(t, func() { Register(stubRegistration("")) }, "empty type")
+	assert.Panics(t, func() { Register(Registration{Type: "stub_c"}) }, "missing processor")
+
+	fallback := stubRegistration("stub_fallback")
+	fallback.Fallback = true
+	Register(fallback)
+	second := stubRegistration("stub_fallback_2")
+	second.Fallback = true
+	assert.Panics(t, func() { Register(second) }, "second fallback")
+
+	assert.Len(t, Registrations(), 2)
+}
+
+func TestLookupUnknownType(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(stubRegistration("stub_a"))
+
+	_, ok := Lookup("stub_unknown")
+	assert.False(t, ok)
+
+	// 没有兜底类型时返回错误
+	_, err := Resolve("stub_unknown")
+	assert.Error(t, err)
+
+	fallback := stubRegistration("stub_fallback")
+	fallback.Fallback = true
+	Register(fallback)
+
+	got, err := Resolve("stub_unknown")
+	require.NoError(t, err)
+	assert.Equal(t, DocumentType("stub_fallback"), got.Type)
+
+	got, err = Resolve("stub_a")
+	require.NoError(t, err)
+	assert.Equal(t, DocumentType("stub_a"), got.Type)
+}
+
+func TestOverride(t *testing.T) {
+	withCleanRegistry(t)
+
+	Register(stubRegistration("stub_a"))
+	Register(stubRegistration("stub_b"))
+	fallback := stubRegistration("stub_fallback")
+	fallback.Fallback = true
+	Register(fallback)
+
+	r := stubRegistration("stub_a")
+	r.Name = "覆盖"
+	require.NoError(t, Override(r))
+	got, _ := Lookup("stub_a")
+	assert.Equal(t, "覆盖", got.Name)
+	// 保留原有的注册顺序
+	assert.Equal(t, DocumentType("stub_a"), Registrations()[0].Type)
+
+	assert.Error(t, Override(stubRegistration("stub_unknown")))
+	assert.Error(t, Override(stubRegistration("stub_fallback")))
+}
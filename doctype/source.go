@@ -0,0 +1,33 @@
+package doctype
+
+// Sourced 可以记录原始文件的文档
+type Sourced interface {
+	SetSource(path string)
+	SetThumbnail(image []byte)
+}
+
+// Source 记录文档识别自哪个文件，嵌入到需要在导出中链接原始文件的文档中
+type Source struct {
+	sourcePath string
+	thumbnail  []byte
+}
+
+// SetSource 记录原始文件的路径
+func (s *Source) SetSource(path string) {
+	s.sourcePath = path
+}
+
+// SourcePath 返回原始文件的路径，未记录时返回空字符串
+func (s *Source) SourcePath() string {
+	return s.sourcePath
+}
+
+// SetThumbnail 记录原始文件的 JPEG 缩略图
+func (s *Source) SetThumbnail(image []byte) {
+	s.thumbnail = image
+}
+
+// Thumbnail 返回原始文件的缩略图，未生成时返回 nil
+func (s *Source) Thumbnail() []byte {
+	return s.thumbnail
+}
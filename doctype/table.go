@@ -0,0 +1,105 @@
+package doctype
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnType 列的数据类型，导出器据此决定单元格格式
+type ColumnType int
+
+const (
+	ColumnText ColumnType = iota
+	ColumnNumber
+	ColumnMoney
+	ColumnDate
+	// ColumnLink 值为原始文件的路径，Excel 中写为超链接
+	ColumnLink
+	// ColumnImage 值为 JPEG 图像，只在 Excel 中嵌入，其他格式不导出该列
+	ColumnImage
+)
+
+// ColumnRole 列的含义，汇总表据此统计各类票据的张数与金额，打印的票据据此标注票号与金额
+type ColumnRole string
+
+const (
+	RoleAmount  ColumnRole = "amount"
+	RoleTax     ColumnRole = "tax"
+	RoleTaxRate ColumnRole = "tax_rate"
+	RoleDate    ColumnRole = "date"
+	RolePerson  ColumnRole = "person"
+	RoleNumber  ColumnRole = "number"
+)
+
+// Column 导出列的元数据
+type Column struct {
+	// Key 机器可读的字段名，用于 JSON 等格式
+	Key string
+	// Header 表头文字
+	Header string
+	Type   ColumnType
+	// Format 数字或日期格式，如"0.00"、"yyyy-mm-dd"，为空时按原值导出
+	Format string
+	// Role 列的含义，同一张表中每种含义最多一列，汇总类报表不设置以免重复统计
+	Role ColumnRole
+	// Warning 值不为空时表示该行需要复核，如重复报销、合规问题，Excel 中高亮显示
+	Warning bool
+}
+
+// Sheet 一张导出表，Rows 中每一行的值与 Columns 一一对应
+type Sheet struct {
+	Name    string
+	Columns []Column
+	Rows    [][]interface{}
+	// Keys 唯一标识一行的列 Key，合并到已有表格时据此去重，为空时直接追加
+	Keys []string
+	// Derived 由其他表格派生（如导出布局生成的表），行与来源表重复，汇总与打印时忽略
+	Derived bool
+}
+
+// Record 返回第 i 行以列 Key 为键的记录
+func (s *Sheet) Record(i int) map[string]interface{} {
+	record := make(map[string]interface{}, len(s.Columns))
+	for j, column := range s.Columns {
+		key := column.Key
+		if key == "" {
+			key = column.Header
+		}
+		if j < len(s.Rows[i]) {
+			record[key] = s.Rows[i][j]
+		} else {
+			record[key] = nil
+		}
+	}
+	return record
+}
+
+// Headers 返回表头文字
+func (s *Sheet) Headers() []string {
+	headers := make([]string, 0, len(s.Columns))
+	for _, column := range s.Columns {
+		headers = append(headers, column.Header)
+	}
+	return headers
+}
+
+// maxSheetNameLength Excel 表名的最大长度（字符数）
+const maxSheetNameLength = 31
+
+// sheetNameInvalidChars Excel 表名中不允许出现的字符
+const sheetNameInvalidChars = `[]:*?/\`
+
+// ValidateSheetName 检查表名能否用作 Excel 工作表名称，以便在加载配置时而不是导出时报错
+func ValidateSheetName(name string) error {
+	if name == "" {
+		return fmt.Errorf("sheet name is empty")
+	}
+	if n := utf8.RuneCountInString(name); n > maxSheetNameLength {
+		return fmt.Errorf("sheet name %s is %d characters long, at most %d are allowed", name, n, maxSheetNameLength)
+	}
+	if strings.ContainsAny(name, sheetNameInvalidChars) {
+		return fmt.Errorf("sheet name %s must not contain any of %s", name, sheetNameInvalidChars)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package doctype
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSheetName(t *testing.T) {
+	assert.NoError(t, ValidateSheetName("增值税发票"))
+	assert.NoError(t, ValidateSheetName(strings.Repeat("表", 31)))
+
+	assert.Error(t, ValidateSheetName(""))
+	assert.Error(t, ValidateSheetName(strings.Repeat("表", 32)))
+	for _, c := range []string{"[", "]", ":", "*", "?", "/", `\`} {
+		assert.Error(t, ValidateSheetName("发票"+c), c)
+	}
+}
@@ -0,0 +1,303 @@
+package employee
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"encoding/csv"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var logger = config.GetLogger()
+
+// 匹配状态
+const (
+	StatusMatched   = "已匹配"
+	StatusUnknown   = "未找到"
+	StatusAmbiguous = "重名"
+)
+
+// headerAliases 目录文件表头与字段的对应关系，中英文表头均可
+var headerAliases = map[string]string{
+	"姓名": "name", "name": "name",
+	"工号": "employee_id", "employee_id": "employee_id",
+	"部门": "department", "department": "department",
+	"成本中心": "cost_center", "cost_center": "cost_center",
+	"职级": "grade", "grade": "grade",
+	"别名": "aliases", "aliases": "aliases",
+}
+
+// Employee 员工信息
+type Employee struct {
+	Name       string
+	ID         string
+	Department string
+	CostCenter string
+	Grade      string
+	Aliases    []string
+}
+
+// Match 人员姓名的匹配结果
+type Match struct {
+	Status   string
+	Employee *Employee
+	// Candidates 重名时的候选员工
+	Candidates []*Employee
+}
+
+// Directory 员工目录
+type Directory struct {
+	employees []*Employee
+	byName    map[string][]*Employee
+}
+
+// NewDirectory 根据员工列表建立目录，姓名与别名均可用于匹配
+func NewDirectory(employees []*Employee) *Directory {
+	d := &Directory{
+		employees: employees,
+		byName:    make(map[string][]*Employee),
+	}
+	for _, e := range employees {
+		names := append([]string{e.Name}, e.Aliases...)
+		seen := make(map[string]bool)
+		for _, name := range names {
+			name = normalizeName(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			d.byName[name] = append(d.byName[name], e)
+		}
+	}
+	return d
+}
+
+// normalizeName 去掉姓名中的空白
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(name), "")
+}
+
+// Load 读取 CSV 或 XLSX 格式的员工目录，第一行为表头，
+// 包含姓名、工号、部门、成本中心、职级与别名（多个别名以分号分隔）
+func Load(path string) (*Directory, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx":
+		rows, err = readXLSX(path)
+	case ".csv":
+		rows, err = readCSV(path)
+	default:
+		return nil, fmt.Errorf("unsupported employee directory format: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("employee directory %s is empty", path)
+	}
+
+	columns := make(map[string]int)
+	for i, header := range rows[0] {
+		header = strings.TrimPrefix(strings.TrimSpace(header), "\ufeff")
+		if field, ok := headerAliases[strings.ToLower(header)]; ok {
+			columns[field] = i
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("employee directory %s has no name column", path)
+	}
+
+	cell := func(row []string, field string) string {
+		i, ok := columns[field]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	employees := make([]*Employee, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		e := &Employee{
+			Name:       normalizeName(cell(row, "name")),
+			ID:         cell(row, "employee_id"),
+			Department: cell(row, "department"),
+			CostCenter: cell(row, "cost_center"),
+			Grade:      cell(row, "grade"),
+			Aliases:    splitAliases(cell(row, "aliases")),
+		}
+		if e.Name != "" {
+			employees = append(employees, e)
+		}
+	}
+
+	logger.Infof("Loaded %d employees from %s", len(employees), path)
+	return NewDirectory(employees), nil
+}
+
+func splitAliases(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ';' || r == '；' || r == ',' || r == '，' || r == '、'
+	})
+}
+
+func readCSV(path string) ([][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open employee directory: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	return reader.ReadAll()
+}
+
+func readXLSX(path string) ([][]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open employee directory: %w", err)
+	}
+	defer f.Close()
+
+	// 读取第一个工作表
+	return f.GetRows(f.GetSheetName(0))
+}
+
+// Match 按姓名或别名匹配员工。票面上的姓名可能带有"*"掩码（如"张*三"），
+// 此时按长度与未遮挡的字匹配
+func (d *Directory) Match(name string) Match {
+	name = normalizeName(name)
+	if name == "" {
+		return Match{Status: StatusUnknown}
+	}
+
+	candidates := d.byName[name]
+	if len(candidates) == 0 && strings.Contains(name, "*") {
+		candidates = d.matchMasked(name)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return Match{Status: StatusUnknown}
+	case 1:
+		return Match{Status: StatusMatched, Employee: candidates[0]}
+	default:
+		return Match{Status: StatusAmbiguous, Candidates: candidates}
+	}
+}
+
+func (d *Directory) matchMasked(masked string) []*Employee {
+	pattern := []rune(masked)
+	candidates := make([]*Employee, 0)
+	for _, e := range d.employees {
+		name := []rune(e.Name)
+		if len(name) != len(pattern) {
+			continue
+		}
+		matched := true
+		for i, r := range pattern {
+			if r != '*' && r != name[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			candidates = append(candidates, e)
+		}
+	}
+	return candidates
+}
+
+// Grade 返回姓名对应员工的职级，无法唯一确定时返回空字符串
+func (d *Directory) Grade(name string) string {
+	if m := d.Match(name); m.Employee != nil {
+		return m.Employee.Grade
+	}
+	return ""
+}
+
+// Assignment 文档所属员工的信息，嵌入到需要关联员工的文档中
+type Assignment struct {
+	EmployeeID string
+	Department string
+	CostCenter string
+	Grade      string
+	// MatchStatus 未找到或重名时的匹配状态，匹配成功时为空，导出时非空即高亮提示复核
+	MatchStatus string
+}
+
+// Assign 记录匹配结果
+func (a *Assignment) Assign(m Match) {
+	a.MatchStatus = ""
+	if m.Status != StatusMatched {
+		a.MatchStatus = m.Status
+	}
+	if m.Employee != nil {
+		a.EmployeeID = m.Employee.ID
+		a.Department = m.Employee.Department
+		a.CostCenter = m.Employee.CostCenter
+		a.Grade = m.Employee.Grade
+	}
+}
+
+// Subject 可以关联员工的文档
+type Subject interface {
+	PersonName() string
+	Assign(m Match)
+}
+
+// Enrich 为所有可关联员工的文档匹配员工，未找到或重名时记录警告。
+// 没有人员姓名的文档（如未声明人员列的自定义类型）记为未找到，但不记录警告
+func Enrich(d *Directory, docs []doctype.Document) {
+	for _, doc := range docs {
+		subject, ok := doc.(Subject)
+		if !ok {
+			continue
+		}
+
+		name := subject.PersonName()
+		m := d.Match(name)
+		switch {
+		case name == "":
+		case m.Status == StatusUnknown:
+			logger.Warnf("Employee %q not found in directory", name)
+		case m.Status == StatusAmbiguous:
+			ids := make([]string, 0, len(m.Candidates))
+			for _, c := range m.Candidates {
+				ids = append(ids, c.ID)
+			}
+			logger.Warnf("Employee %q is ambiguous: %s", name, strings.Join(ids, ", "))
+		}
+		subject.Assign(m)
+	}
+}
+
+// departmentOf 返回文档所属部门，未关联员工的文档返回空字符串
+func departmentOf(doc doctype.Document) string {
+	if a, ok := doc.(interface{ AssignedDepartment() string }); ok {
+		return a.AssignedDepartment()
+	}
+	return ""
+}
+
+// AssignedDepartment 返回关联员工的部门
+func (a *Assignment) AssignedDepartment() string {
+	return a.Department
+}
+
+// SortByDepartment 按部门对文档稳定排序，未关联部门的文档排在最后，
+// 导出时同一部门的记录因此相邻
+func SortByDepartment(docs []doctype.Document) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		di, dj := departmentOf(docs[i]), departmentOf(docs[j])
+		if (di == "") != (dj == "") {
+			return di != ""
+		}
+		return di < dj
+	})
+}
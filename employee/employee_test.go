@@ -0,0 +1,65 @@
+package employee
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAndMatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "employees.csv")
+	content := "姓名,工号,部门,成本中心,职级,别名\n" +
+		"张三,E001,研发部,CC100,P5,张叁;Zhang San\n" +
+		"李四,E002,财务部,CC200,P6,\n" +
+		"王五,E003,研发部,CC100,P4,\n" +
+		"王五,E004,市场部,CC300,P5,\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	directory, err := Load(path)
+	require.NoError(t, err)
+
+	m := directory.Match("张三")
+	assert.Equal(t, StatusMatched, m.Status)
+	assert.Equal(t, "E001", m.Employee.ID)
+
+	// 别名与姓名中的空白
+	m = directory.Match("Zhang San")
+	assert.Equal(t, StatusMatched, m.Status)
+	assert.Equal(t, "E001", m.Employee.ID)
+
+	// 票面上带掩码的姓名
+	m = directory.Match("李*")
+	assert.Equal(t, StatusMatched, m.Status)
+	assert.Equal(t, "CC200", m.Employee.CostCenter)
+
+	m = directory.Match("王五")
+	assert.Equal(t, StatusAmbiguous, m.Status)
+	assert.Len(t, m.Candidates, 2)
+	assert.Equal(t, "", directory.Grade("王五"))
+
+	assert.Equal(t, StatusUnknown, directory.Match("赵六").Status)
+	assert.Equal(t, "P6", directory.Grade("李四"))
+}
+
+func TestAssignLeavesStatusEmptyWhenMatched(t *testing.T) {
+	directory := NewDirectory([]*Employee{
+		{Name: "张三", ID: "E001", Department: "研发部"},
+		{Name: "李四", ID: "E002"},
+		{Name: "李四", ID: "E003"},
+	})
+
+	// 匹配成功时不写状态，避免导出时被当作需要复核而高亮
+	var a Assignment
+	a.Assign(directory.Match("张三"))
+	assert.Equal(t, "E001", a.EmployeeID)
+	assert.Empty(t, a.MatchStatus)
+
+	a.Assign(directory.Match("李四"))
+	assert.Equal(t, StatusAmbiguous, a.MatchStatus)
+
+	a.Assign(directory.Match("王五"))
+	assert.Equal(t, StatusUnknown, a.MatchStatus)
+}
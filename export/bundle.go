@@ -0,0 +1,485 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"FinDocOCR/utils"
+	"bytes"
+	"fmt"
+	"github.com/go-pdf/fpdf"
+	"github.com/go-pdf/fpdf/contrib/gofpdi"
+	"github.com/xuri/excelize/v2"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 打印页面的布局，单位为毫米
+const (
+	pageWidth    = 210.0
+	pageHeight   = 297.0
+	pageMargin   = 10.0
+	cellGap      = 4.0
+	headerHeight = 6.0
+	fontSize     = 9.0
+)
+
+// Grid 每页排列的格子数
+type Grid struct {
+	Columns int
+	Rows    int
+}
+
+// DefaultGrid 默认每页两列两行
+var DefaultGrid = Grid{Columns: 2, Rows: 2}
+
+// ParseGrid 解析"列x行"形式的格子数，如"2x3"，为空时返回 DefaultGrid
+func ParseGrid(s string) (Grid, error) {
+	if s == "" {
+		return DefaultGrid, nil
+	}
+	columns, rows, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return Grid{}, fmt.Errorf("invalid grid %q, expected columns x rows such as 2x2", s)
+	}
+	var grid Grid
+	var err1, err2 error
+	grid.Columns, err1 = strconv.Atoi(strings.TrimSpace(columns))
+	grid.Rows, err2 = strconv.Atoi(strings.TrimSpace(rows))
+	if err1 != nil || err2 != nil || grid.Columns < 1 || grid.Rows < 1 {
+		return Grid{}, fmt.Errorf("invalid grid %q, expected columns x rows such as 2x2", s)
+	}
+	return grid, nil
+}
+
+// bundleItem 打印的一张票据
+type bundleItem struct {
+	sheet  string
+	row    int
+	number string
+	amount string
+	path   string
+}
+
+// BundleExporter 将各类票据的原始图片与 PDF 页面按导出的顺序排入 A4 页面，生成用于打印提交的 PDF。
+// 每个格子上方标注表名、表格中的行号、票号与金额，与导出的表格一一对应。
+// 除随识别一起导出外，也可以通过 AddWorkbook 从已导出的工作簿重新生成，不需要再次识别。
+type BundleExporter struct {
+	// Path 生成的 PDF 文件路径
+	Path string
+	Grid Grid
+	// FontFile 支持中文的 TrueType 字体，为空时标注中只保留 ASCII 字符
+	FontFile string
+	// Dir 表格以 Excel 导出时的导出目录。设置后 Finish 时从最终写入的工作簿读取表名与行号，
+	// 追加到已有工作簿、写入模板或合并工作簿时标注仍与表格一致；为空时按新建的表格推算
+	Dir string
+	// Workbook 合并工作簿的文件名（不含扩展名），所有表格写入同一个工作簿时设置
+	Workbook string
+	items    []bundleItem
+	// outputs 有票据的导出文件名，known 为这些表格的列定义，用于在写入的工作簿中查找对应的行
+	outputs []string
+	known   []doctype.Sheet
+}
+
+// Export 收集有原始文件的行，在 Finish 时统一排版
+func (e *BundleExporter) Export(name string, sheets []doctype.Sheet) error {
+	for i := range sheets {
+		items := bundleItems(&sheets[i], nil)
+		if len(items) == 0 {
+			continue
+		}
+		e.items = append(e.items, items...)
+		e.known = append(e.known, doctype.Sheet{Name: sheets[i].Name, Columns: sheets[i].Columns, Keys: sheets[i].Keys})
+		if len(e.outputs) == 0 || e.outputs[len(e.outputs)-1] != name {
+			e.outputs = append(e.outputs, name)
+		}
+	}
+	return nil
+}
+
+// AddWorkbook 读取已导出的工作簿中链接了原始文件的行，表名与行号取自工作簿。
+// known 为各类票据表格的列定义，用于按表头确定票号与金额列
+func (e *BundleExporter) AddWorkbook(filename string, known []doctype.Sheet) error {
+	items, err := workbookItems(filename, known)
+	if err != nil {
+		return err
+	}
+	e.items = append(e.items, items...)
+	return nil
+}
+
+// locateRows 以最终写入的工作簿中的表名与行号替换按新建表格推算的位置。
+// 追加时没有唯一键的行会重复出现，从后往前对应，使本次导出的行对应到最后写入的位置
+func (e *BundleExporter) locateRows() {
+	files := e.outputs
+	if e.Workbook != "" {
+		files = []string{e.Workbook}
+	}
+
+	written := make(map[string][]bundleItem)
+	for _, name := range files {
+		items, err := workbookItems(filepath.Join(e.Dir, name+".xlsx"), e.known)
+		if err != nil {
+			logger.Warnf("Bundle: %v, row numbers of %s assume a new sheet", err, name)
+			continue
+		}
+		for _, item := range items {
+			written[item.path] = append(written[item.path], item)
+		}
+	}
+
+	for i := len(e.items) - 1; i >= 0; i-- {
+		item := &e.items[i]
+		positions := written[item.path]
+		if len(positions) == 0 {
+			logger.Warnf("Bundle: %s not found in the exported workbooks, row number assumes a new sheet", item.path)
+			continue
+		}
+		last := positions[len(positions)-1]
+		item.sheet, item.row = last.sheet, last.row
+		written[item.path] = positions[:len(positions)-1]
+	}
+}
+
+// workbookItems 读取工作簿中链接了原始文件的行，行号为工作表中的实际行号。
+// 工作表按表头文字对应到 known 中表头最吻合的表格；汇总表与导出布局生成的表没有原始文件的超链接，不会读取
+func workbookItems(filename string, known []doctype.Sheet) ([]bundleItem, error) {
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer f.Close()
+
+	var items []bundleItem
+	for _, sheetName := range f.GetSheetList() {
+		rows, err := f.GetRows(sheetName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read sheet %s: %w", sheetName, err)
+		}
+		headerRow, matched, positions := matchHeader(rows, known)
+		if matched == nil {
+			continue
+		}
+
+		sheet := doctype.Sheet{Name: sheetName, Columns: matched.Columns, Keys: matched.Keys}
+		rowNumbers := make([]int, 0, len(rows)-headerRow)
+		for r := headerRow + 1; r <= len(rows); r++ {
+			values := make([]interface{}, len(sheet.Columns))
+			for i, column := range sheet.Columns {
+				col := positions[i]
+				switch {
+				case col == 0:
+				case column.Type == doctype.ColumnLink:
+					cell, _ := excelize.CoordinatesToCellName(col, r)
+					values[i] = linkPath(f, sheetName, cell)
+				case col <= len(rows[r-1]):
+					values[i] = rows[r-1][col-1]
+				}
+			}
+			sheet.Rows = append(sheet.Rows, values)
+			rowNumbers = append(rowNumbers, r)
+		}
+		items = append(items, bundleItems(&sheet, rowNumbers)...)
+	}
+	return items, nil
+}
+
+// matchHeader 查找第一个包含原始文件列表头的行，返回表头所在行、表头相同的列最多的表格，
+// 以及该表格各列在工作表中的列号（从 1 开始，0 表示工作表中没有该列）
+func matchHeader(rows [][]string, known []doctype.Sheet) (headerRow int, sheet *doctype.Sheet, positions []int) {
+	best := 0
+	for r, row := range rows {
+		columns := make(map[string]int, len(row))
+		for c, text := range row {
+			if text = strings.TrimSpace(text); text != "" {
+				if _, exists := columns[text]; !exists {
+					columns[text] = c + 1
+				}
+			}
+		}
+
+		for i := range known {
+			matched, linked := 0, false
+			cols := make([]int, len(known[i].Columns))
+			for j, column := range known[i].Columns {
+				if col, ok := columns[column.Header]; ok {
+					cols[j] = col
+					matched++
+					if column.Type == doctype.ColumnLink {
+						linked = true
+					}
+				}
+			}
+			if linked && matched > best {
+				best, headerRow, sheet, positions = matched, r+1, &known[i], cols
+			}
+		}
+		if sheet != nil {
+			return headerRow, sheet, positions
+		}
+	}
+	return 0, nil, nil
+}
+
+// linkPath 返回单元格链接的本地文件，新建的表格写入 HYPERLINK 公式，追加与模板合并时写入单元格超链接
+func linkPath(f *excelize.File, sheetName, cell string) string {
+	target := ""
+	if ok, link, err := f.GetCellHyperLink(sheetName, cell); err == nil && ok {
+		target = link
+	} else if formula, err := f.GetCellFormula(sheetName, cell); err == nil {
+		if rest, found := strings.CutPrefix(formula, `HYPERLINK("`); found {
+			target, _, _ = strings.Cut(rest, `"`)
+		}
+	}
+	return localPath(target)
+}
+
+// bundleItems 返回表格中有原始文件的行，没有原始文件列的表格（如报表）与派生的表格不打印。
+// rowNumbers 为各行在工作表中的行号，为 nil 时按新建的表格推算
+func bundleItems(sheet *doctype.Sheet, rowNumbers []int) []bundleItem {
+	if sheet.Derived {
+		return nil
+	}
+	columns := excelColumns(sheet.Columns)
+	link, number, amount := -1, -1, -1
+	for i, column := range columns {
+		switch {
+		case column.Type == doctype.ColumnLink && link == -1:
+			link = i
+		case column.Role == doctype.RoleNumber:
+			number = i
+		case column.Role == doctype.RoleAmount:
+			amount = i
+		}
+	}
+	if link == -1 {
+		return nil
+	}
+
+	record := func(row []interface{}, i int) string {
+		if i == -1 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(formatText(columns[i], row[i]))
+	}
+
+	items := make([]bundleItem, 0, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		path := record(row, link)
+		if path == "" {
+			continue
+		}
+		rowNumber := i + 2
+		if rowNumbers != nil {
+			rowNumber = rowNumbers[i]
+		}
+		item := bundleItem{sheet: sheet.Name, row: rowNumber, number: record(row, number), amount: record(row, amount), path: path}
+		// 没有票号时以唯一键标识
+		if item.number == "" {
+			parts := make([]string, 0, len(sheet.Keys))
+			for _, key := range sheet.Keys {
+				for j, column := range columns {
+					if column.Key == key {
+						if value := record(row, j); value != "" {
+							parts = append(parts, value)
+						}
+					}
+				}
+			}
+			item.number = strings.Join(parts, " ")
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// bundleCell 页面上的一个格子，图片或 PDF 的一页
+type bundleCell struct {
+	header string
+	// image 已注册的图片名，为空时为 PDF 页面
+	image    string
+	template int
+	// width、height 原始尺寸，用于按比例缩放
+	width, height float64
+}
+
+// Finish 排版并写入 PDF
+func (e *BundleExporter) Finish() error {
+	if len(e.items) == 0 {
+		return nil
+	}
+	if e.Dir != "" {
+		e.locateRows()
+	}
+	grid := e.Grid
+	if grid.Columns < 1 || grid.Rows < 1 {
+		grid = DefaultGrid
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	font := "Helvetica"
+	if e.FontFile != "" {
+		font = "bundle"
+		pdf.AddUTF8Font(font, "", e.FontFile)
+		if err := pdf.Error(); err != nil {
+			return fmt.Errorf("failed to load font %s: %w", e.FontFile, err)
+		}
+	}
+	pdf.SetFont(font, "", fontSize)
+
+	importer := gofpdi.NewImporter()
+	cells := make([]bundleCell, 0, len(e.items))
+	for i, item := range e.items {
+		header := e.header(i+1, item)
+		added, err := addBundleCells(pdf, importer, item.path, header)
+		if err != nil {
+			logger.Warnf("Bundle: skipped %s: %v", item.path, err)
+			continue
+		}
+		cells = append(cells, added...)
+	}
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("failed to compose bundle: %w", err)
+	}
+
+	cellWidth := (pageWidth - 2*pageMargin - float64(grid.Columns-1)*cellGap) / float64(grid.Columns)
+	cellHeight := (pageHeight - 2*pageMargin - float64(grid.Rows-1)*cellGap) / float64(grid.Rows)
+	perPage := grid.Columns * grid.Rows
+	for i, cell := range cells {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+		position := i % perPage
+		x := pageMargin + float64(position%grid.Columns)*(cellWidth+cellGap)
+		y := pageMargin + float64(position/grid.Columns)*(cellHeight+cellGap)
+
+		// 格子边框与标注
+		pdf.SetDrawColor(180, 180, 180)
+		pdf.Rect(x, y, cellWidth, cellHeight, "D")
+		pdf.SetXY(x, y)
+		pdf.CellFormat(cellWidth, headerHeight, fitText(pdf, cell.header, cellWidth-2), "B", 0, "L", false, 0, "")
+
+		// 按比例缩放到标注下方的区域并居中
+		areaWidth, areaHeight := cellWidth-2, cellHeight-headerHeight-2
+		scale := min(areaWidth/cell.width, areaHeight/cell.height)
+		w, h := cell.width*scale, cell.height*scale
+		left := x + 1 + (areaWidth-w)/2
+		top := y + headerHeight + 1 + (areaHeight-h)/2
+		if cell.image != "" {
+			pdf.ImageOptions(cell.image, left, top, w, h, false, fpdf.ImageOptions{}, 0, "")
+		} else {
+			importer.UseImportedTemplate(pdf, cell.template, left, top, w, h)
+		}
+	}
+
+	if err := pdf.OutputFileAndClose(e.Path); err != nil {
+		return fmt.Errorf("failed to save file %s: %w", e.Path, err)
+	}
+
+	logger.Infof("Saved %d documents on %d pages to %s", len(e.items), pdf.PageCount(), e.Path)
+	return nil
+}
+
+// header 格子的标注：序号、表名与行号、票号、金额
+func (e *BundleExporter) header(index int, item bundleItem) string {
+	var parts []string
+	if e.FontFile != "" {
+		parts = []string{fmt.Sprintf("%d. %s 第%d行", index, item.sheet, item.row)}
+		if item.number != "" {
+			parts = append(parts, "号码 "+item.number)
+		}
+		if item.amount != "" {
+			parts = append(parts, "金额 "+item.amount)
+		}
+	} else {
+		// 内置字体不支持中文
+		parts = []string{fmt.Sprintf("%d. Row %d", index, item.row)}
+		if number := asciiOnly(item.number); number != "" {
+			parts = append(parts, "No. "+number)
+		}
+		if item.amount != "" {
+			parts = append(parts, "Amount "+asciiOnly(item.amount))
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+// addBundleCells 读取原始文件，图片为一个格子，PDF 每页一个格子
+func addBundleCells(pdf *fpdf.Fpdf, importer *gofpdi.Importer, path, header string) ([]bundleCell, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".pdf" {
+		sizes, err := pdfPageSizes(path)
+		if err != nil {
+			return nil, err
+		}
+		cells := make([]bundleCell, 0, len(sizes))
+		for page := 1; page <= len(sizes); page++ {
+			size := sizes[page]["/MediaBox"]
+			cell := bundleCell{header: header, width: size["w"], height: size["h"]}
+			if len(sizes) > 1 {
+				cell.header = fmt.Sprintf("%s  (%d/%d)", header, page, len(sizes))
+			}
+			cell.template = importer.ImportPage(pdf, path, page, "/MediaBox")
+			cells = append(cells, cell)
+		}
+		return cells, nil
+	}
+
+	// 使用提交识别的压缩图像，控制 PDF 的大小
+	data, err := utils.ImageResize(path)
+	if err != nil {
+		return nil, err
+	}
+	imageType := "JPG"
+	if strings.ToLower(filepath.Ext(path)) == ".png" {
+		imageType = "PNG"
+	}
+	info := pdf.RegisterImageOptionsReader(path, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if err := pdf.Error(); err != nil {
+		// 不支持的图像（如 16 位 PNG）只跳过该文件
+		pdf.ClearError()
+		return nil, err
+	}
+	return []bundleCell{{header: header, image: path, width: info.Width(), height: info.Height()}}, nil
+}
+
+// pdfPageSizes 返回 PDF 各页的尺寸。导入库遇到无法解析的文件时会 panic，
+// 先用单独的导入器读取，避免损坏的文件中断整个排版。
+func pdfPageSizes(path string) (sizes map[int]map[string]map[string]float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read pdf: %v", r)
+		}
+	}()
+	importer := gofpdi.NewImporter()
+	importer.ImportPage(fpdf.New("P", "mm", "A4", ""), path, 1, "/MediaBox")
+	sizes = importer.GetPageSizes()
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("no pages in pdf")
+	}
+	return sizes, nil
+}
+
+// fitText 截断超出宽度的文本
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// asciiOnly 去掉非 ASCII 字符
+func asciiOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"encoding/csv"
+	"fmt"
+	"os"
+)
+
+// utf8BOM 使 Excel 以 UTF-8 编码打开 CSV 文件
+const utf8BOM = "\ufeff"
+
+// CSVExporter 将每张表写入一个带 BOM 的 UTF-8 CSV 文件
+type CSVExporter struct {
+	Dir string
+}
+
+func (e *CSVExporter) Export(name string, sheets []doctype.Sheet) error {
+	for i := range sheets {
+		filename := sheetFilename(e.Dir, name, sheets, i, ".csv")
+		if err := writeCSV(filename, withoutImages(&sheets[i])); err != nil {
+			return err
+		}
+		logger.Info("Saved ", filename)
+	}
+	return nil
+}
+
+func writeCSV(filename string, sheet *doctype.Sheet) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", filename, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if _, err := file.WriteString(utf8BOM); err != nil {
+		return fmt.Errorf("failed to write BOM: %w", err)
+	}
+
+	w := csv.NewWriter(file)
+	if err := w.Write(sheet.Headers()); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+	for i, row := range sheet.Rows {
+		record := make([]string, len(row))
+		for j, value := range row {
+			if j < len(sheet.Columns) {
+				record[j] = formatText(sheet.Columns[j], value)
+			} else {
+				record[j] = formatValue(value)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+2, err)
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,237 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"os"
+	"path/filepath"
+)
+
+// ExcelExporter 将每组表格写入一个工作簿，每张表对应一个工作表
+type ExcelExporter struct {
+	Dir string
+	// Append 工作簿已存在时合并到原有工作簿，而不是覆盖
+	Append bool
+	// Templates 按导出文件名指定的模板
+	Templates map[string]Template
+}
+
+func (e *ExcelExporter) Export(name string, sheets []doctype.Sheet) error {
+	filename := filepath.Join(e.Dir, name+".xlsx")
+	template, hasTemplate := e.Templates[name]
+
+	// 合并时以原有工作簿为底，否则以模板为底
+	var base string
+	switch {
+	case e.Append && fileExists(filename):
+		base = filename
+	case hasTemplate:
+		base = template.Path
+	default:
+		return create(filename, sheets)
+	}
+
+	f, err := excelize.OpenFile(base)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", base, err)
+	}
+	defer f.Close()
+
+	for i, sheet := range sheets {
+		sheetName, startCell := sheet.Name, "A1"
+		if hasTemplate && i == 0 {
+			if template.Sheet != "" {
+				sheetName = template.Sheet
+			}
+			startCell = template.StartCell
+		}
+
+		index, err := f.GetSheetIndex(sheetName)
+		if err != nil {
+			return fmt.Errorf("invalid sheet name %s: %w", sheetName, err)
+		}
+		if index == -1 {
+			if _, err := f.NewSheet(sheetName); err != nil {
+				return fmt.Errorf("failed to create sheet %s: %w", sheetName, err)
+			}
+		}
+
+		if err := mergeSheet(f, sheetName, startCell, &sheet); err != nil {
+			return fmt.Errorf("sheet %s: %w", sheetName, err)
+		}
+	}
+
+	if err := f.SaveAs(filename); err != nil {
+		return fmt.Errorf("failed to save file %s: %w", filename, err)
+	}
+
+	logger.Infof("Merged into %s", filename)
+	return nil
+}
+
+// create 新建工作簿
+func create(filename string, sheets []doctype.Sheet) (err error) {
+	// 初始化 Excel 文件
+	f := excelize.NewFile()
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	for i, sheet := range sheets {
+		// 第一张表沿用默认的 Sheet1，其余新建
+		if i == 0 {
+			if err := f.SetSheetName("Sheet1", sheet.Name); err != nil {
+				return fmt.Errorf("failed to rename sheet: %w", err)
+			}
+		} else if _, err := f.NewSheet(sheet.Name); err != nil {
+			return fmt.Errorf("failed to create sheet %s: %w", sheet.Name, err)
+		}
+
+		if err := writeSheet(f, &sheet); err != nil {
+			return fmt.Errorf("sheet %s: %w", sheet.Name, err)
+		}
+	}
+
+	// 保存文件
+	if err := f.SaveAs(filename); err != nil {
+		return fmt.Errorf("failed to save file %s: %w", filename, err)
+	}
+
+	logger.Info("Saved ", filename)
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeSheet 使用流式写入器写入表头与数据行：表头加粗并冻结，启用筛选，按内容设置列宽，
+// 金额与日期按格式写入，需要复核的列不为空时高亮整行，原始文件列写为超链接，缩略图嵌入所在的单元格
+func writeSheet(f *excelize.File, sheet *doctype.Sheet) error {
+	sheet = withoutEmptyImages(sheet)
+	columns := excelColumns(sheet.Columns)
+	lastRow := len(sheet.Rows) + 1
+
+	// 筛选与条件格式需要在创建流式写入器之前设置
+	if len(columns) > 0 {
+		lastCol, _ := excelize.ColumnNumberToName(len(columns))
+		if err := f.AutoFilter(sheet.Name, fmt.Sprintf("A1:%s%d", lastCol, lastRow), nil); err != nil {
+			return fmt.Errorf("failed to set auto filter: %w", err)
+		}
+		if err := highlightWarnings(f, sheet.Name, columns, lastRow); err != nil {
+			return err
+		}
+	}
+
+	images, err := addImages(f, sheet)
+	if err != nil {
+		return err
+	}
+
+	sw, err := f.NewStreamWriter(sheet.Name)
+	if err != nil {
+		return fmt.Errorf("failed to create stream writer: %w", err)
+	}
+
+	// 列宽与冻结窗格需要在写入行之前设置
+	for i, width := range columnWidths(columns, sheet.Rows) {
+		if imageWidth, ok := images.widths[i]; ok {
+			width = imageWidth
+		}
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return fmt.Errorf("failed to set column width: %w", err)
+		}
+	}
+	if err := sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return fmt.Errorf("failed to freeze header: %w", err)
+	}
+
+	// 写入表头
+	headerStyleID, err := headerStyle(f)
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+	headers := make([]interface{}, 0, len(columns))
+	for _, header := range sheet.Headers() {
+		headers = append(headers, excelize.Cell{StyleID: headerStyleID, Value: header})
+	}
+	if err := sw.SetRow("A1", headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+
+	// 设置了格式的列按格式写入
+	styles := newNumberStyles(f)
+	styleIDs := make([]int, len(columns))
+	for i, column := range columns {
+		if column.Format == "" {
+			continue
+		}
+		if styleIDs[i], err = styles.get(column.Format); err != nil {
+			return err
+		}
+	}
+	linkStyleID, err := linkStyle(f)
+	if err != nil {
+		return fmt.Errorf("failed to create link style: %w", err)
+	}
+
+	// 写入数据行
+	for i, row := range sheet.Rows {
+		cells := make([]interface{}, len(row))
+		var opts []excelize.RowOpts
+		for j, value := range row {
+			switch {
+			case j >= len(columns):
+				cells[j] = value
+			case columns[j].Type == doctype.ColumnImage:
+				// 图像已嵌入，单元格留空
+				if len(imageData(value)) > 0 {
+					opts = []excelize.RowOpts{{Height: images.rowHeight}}
+				}
+			case columns[j].Type == doctype.ColumnLink:
+				if path, _ := value.(string); path != "" {
+					cells[j] = excelize.Cell{StyleID: linkStyleID, Formula: hyperlinkFormula(path), Value: linkText(path)}
+				}
+			case styleIDs[j] != 0:
+				cells[j] = excelize.Cell{StyleID: styleIDs[j], Value: typedValue(columns[j], value)}
+			default:
+				cells[j] = value
+			}
+		}
+		if err := sw.SetRow(fmt.Sprintf("A%d", i+2), cells, opts...); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+2, err)
+		}
+	}
+
+	// 刷新流式写入器
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush stream writer: %w", err)
+	}
+	return nil
+}
+
+// numberStyles 按格式缓存工作簿中的数字格式样式
+type numberStyles struct {
+	f   *excelize.File
+	ids map[string]int
+}
+
+func newNumberStyles(f *excelize.File) *numberStyles {
+	return &numberStyles{f: f, ids: make(map[string]int)}
+}
+
+func (s *numberStyles) get(format string) (int, error) {
+	if id, ok := s.ids[format]; ok {
+		return id, nil
+	}
+	id, err := s.f.NewStyle(&excelize.Style{CustomNumFmt: &format})
+	if err != nil {
+		return 0, fmt.Errorf("invalid format %q: %w", format, err)
+	}
+	s.ids[format] = id
+	return id, nil
+}
@@ -0,0 +1,125 @@
+package export
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var logger = config.GetLogger()
+
+// 支持的导出格式
+const (
+	FormatExcel     = "xlsx"
+	FormatCSV       = "csv"
+	FormatJSON      = "json"
+	FormatJSONLines = "jsonl"
+)
+
+// Exporter 将一组表格写入文件，name 为不含扩展名的文件名
+type Exporter interface {
+	Export(name string, sheets []doctype.Sheet) error
+}
+
+// Options 导出选项
+type Options struct {
+	// Dir 导出目录，为空时为当前目录
+	Dir string
+	// Append 导出文件已存在时合并到原有文件中，目前只有 Excel 支持
+	Append bool
+	// Templates 按导出文件名（不含扩展名）指定的 Excel 模板
+	Templates map[string]Template
+	// Workbook 不为空时将所有导出写入以此命名的一个工作簿，并生成汇总表，只支持 Excel
+	Workbook string
+}
+
+// New 根据格式创建导出器，format 为空时导出 Excel
+func New(format string, options Options) (Exporter, error) {
+	dir := options.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	if IsExcel(format) {
+		if options.Workbook != "" {
+			if options.Append || len(options.Templates) > 0 {
+				logger.Warn("append and templates are not supported by a consolidated workbook, ignored")
+			}
+			return &WorkbookExporter{Dir: dir, Name: options.Workbook}, nil
+		}
+		return &ExcelExporter{Dir: dir, Append: options.Append, Templates: options.Templates}, nil
+	}
+
+	if options.Workbook != "" {
+		return nil, fmt.Errorf("a consolidated workbook requires Excel export, got %s", format)
+	}
+
+	if options.Append || len(options.Templates) > 0 {
+		logger.Warnf("append and templates are only supported by Excel export, ignored for %s", format)
+	}
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return &CSVExporter{Dir: dir}, nil
+	case FormatJSON:
+		return &JSONExporter{Dir: dir}, nil
+	case FormatJSONLines, "jsonlines", "ndjson":
+		return &JSONLinesExporter{Dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// IsExcel 判断导出格式是否为 Excel，为空时导出 Excel
+func IsExcel(format string) bool {
+	switch strings.ToLower(format) {
+	case "", FormatExcel, "excel":
+		return true
+	}
+	return false
+}
+
+// Finish 完成导出，需要在所有导出之后调用，用于写入汇总了多次导出的文件
+func Finish(exporter Exporter) error {
+	if f, ok := exporter.(interface{ Finish() error }); ok {
+		return f.Finish()
+	}
+	return nil
+}
+
+// Combine 将每次导出同时交给多个导出器
+func Combine(exporters ...Exporter) Exporter {
+	return multiExporter(exporters)
+}
+
+type multiExporter []Exporter
+
+func (m multiExporter) Export(name string, sheets []doctype.Sheet) error {
+	var errs []error
+	for _, exporter := range m {
+		if err := exporter.Export(name, sheets); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiExporter) Finish() error {
+	var errs []error
+	for _, exporter := range m {
+		if err := Finish(exporter); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sheetFilename 一个文件只能容纳一张表的格式（CSV、JSON Lines）在多张表时以表名区分文件
+func sheetFilename(dir, name string, sheets []doctype.Sheet, i int, ext string) string {
+	if len(sheets) == 1 {
+		return filepath.Join(dir, name+ext)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s_%s%s", name, sheets[i].Name, ext))
+}
@@ -0,0 +1,580 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"bytes"
+	"encoding/json"
+	"github.com/go-pdf/fpdf"
+	"github.com/xuri/excelize/v2"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSheets() []doctype.Sheet {
+	return []doctype.Sheet{
+		{
+			Name: "火车票",
+			Columns: []doctype.Column{
+				{Key: "name", Header: "人员"},
+				{Key: "fare", Header: "票价", Type: doctype.ColumnMoney},
+			},
+			Rows: [][]interface{}{
+				{"张三", 553.0},
+				{"李四", 0.5},
+			},
+		},
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, (&CSVExporter{Dir: dir}).Export("火车票处理结果", testSheets()))
+
+	content, err := os.ReadFile(filepath.Join(dir, "火车票处理结果.csv"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), utf8BOM))
+	assert.Equal(t, "人员,票价\n张三,553.00\n李四,0.50\n", strings.TrimPrefix(string(content), utf8BOM))
+}
+
+func TestJSONExporter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, (&JSONExporter{Dir: dir}).Export("火车票处理结果", testSheets()))
+
+	content, err := os.ReadFile(filepath.Join(dir, "火车票处理结果.json"))
+	require.NoError(t, err)
+
+	var records []map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &records))
+	require.Len(t, records, 2)
+	assert.Equal(t, "张三", records[0]["name"])
+	assert.Equal(t, 553.0, records[0]["fare"])
+}
+
+func TestJSONLinesExporter(t *testing.T) {
+	dir := t.TempDir()
+	sheets := append(testSheets(), doctype.Sheet{
+		Name:    "汇总",
+		Columns: []doctype.Column{{Key: "count", Header: "张数"}},
+		Rows:    [][]interface{}{{2}},
+	})
+	require.NoError(t, (&JSONLinesExporter{Dir: dir}).Export("火车票处理结果", sheets))
+
+	// 多张表时每张表写入单独的文件
+	content, err := os.ReadFile(filepath.Join(dir, "火车票处理结果_火车票.jsonl"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"name":"李四","fare":0.5}`, lines[1])
+
+	_, err = os.Stat(filepath.Join(dir, "火车票处理结果_汇总.jsonl"))
+	assert.NoError(t, err)
+}
+
+func TestNew(t *testing.T) {
+	exporter, err := New("", Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &ExcelExporter{}, exporter)
+
+	exporter, err = New("NDJSON", Options{Dir: "out"})
+	require.NoError(t, err)
+	assert.IsType(t, &JSONLinesExporter{}, exporter)
+
+	_, err = New("pdf", Options{})
+	assert.Error(t, err)
+}
+
+func TestExcelFormatting(t *testing.T) {
+	dir := t.TempDir()
+	sheets := testSheets()
+	sheets[0].Columns = append(sheets[0].Columns,
+		doctype.Column{Key: "duplicate", Header: "重复", Warning: true},
+		doctype.Column{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	)
+	sheets[0].Rows[0] = append(sheets[0].Rows[0], "", "/票据/2024/火车票 01.jpg")
+	sheets[0].Rows[1] = append(sheets[0].Rows[1], "与 火车票 01.jpg 重复", "")
+	require.NoError(t, (&ExcelExporter{Dir: dir}).Export("火车票处理结果", sheets))
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "火车票处理结果.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// 表头冻结并启用筛选
+	panes, err := f.GetPanes("火车票")
+	require.NoError(t, err)
+	assert.True(t, panes.Freeze)
+	assert.Equal(t, 1, panes.YSplit)
+	filter := f.GetDefinedName()
+	require.Len(t, filter, 1)
+	assert.Equal(t, "'火车票'!$A$1:$D$3", filter[0].RefersTo)
+
+	// 金额按默认格式写入，原始文件写为超链接
+	rows, err := f.GetRows("火车票")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"张三", "553.00", "", "火车票 01.jpg"}, rows[1])
+	formula, err := f.GetCellFormula("火车票", "D2")
+	require.NoError(t, err)
+	assert.Equal(t, `HYPERLINK("file:///%E7%A5%A8%E6%8D%AE/2024/%E7%81%AB%E8%BD%A6%E7%A5%A8%2001.jpg","火车票 01.jpg")`, formula)
+
+	// 重复列不为空时整行高亮
+	formats, err := f.GetConditionalFormats("火车票")
+	require.NoError(t, err)
+	require.Contains(t, formats, "A2:D3")
+	assert.Equal(t, "LEN($C2)>0", formats["A2:D3"][0].Criteria)
+
+	width, err := f.GetColWidth("火车票", "C")
+	require.NoError(t, err)
+	assert.Equal(t, float64(displayWidth("与 火车票 01.jpg 重复")+2), width)
+}
+
+func TestExcelThumbnails(t *testing.T) {
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 80, 160))
+	var buffer bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buffer, img, nil))
+
+	sheets := testSheets()
+	sheets[0].Columns = append(sheets[0].Columns,
+		doctype.Column{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+		doctype.Column{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+	)
+	sheets[0].Rows[0] = append(sheets[0].Rows[0], "/票据/01.jpg", buffer.Bytes())
+	sheets[0].Rows[1] = append(sheets[0].Rows[1], "/票据/02.pdf", []byte(nil))
+	require.NoError(t, (&ExcelExporter{Dir: dir}).Export("火车票处理结果", sheets))
+	require.NoError(t, (&CSVExporter{Dir: dir}).Export("火车票处理结果", sheets))
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "火车票处理结果.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	// 缩略图嵌入所在行，行高容纳缩略图
+	pictures, err := f.GetPictures("火车票", "D2")
+	require.NoError(t, err)
+	require.Len(t, pictures, 1)
+	assert.Equal(t, buffer.Bytes(), pictures[0].File)
+	height, err := f.GetRowHeight("火车票", 2)
+	require.NoError(t, err)
+	assert.Equal(t, float64(160+2*imageMargin)*0.75, height)
+
+	// CSV 不导出图像列
+	content, err := os.ReadFile(filepath.Join(dir, "火车票处理结果.csv"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(strings.TrimPrefix(string(content), utf8BOM), "人员,票价,原始文件\n"))
+
+	// 没有缩略图时不导出空的图像列
+	sheets[0].Rows[0][3] = []byte(nil)
+	require.NoError(t, (&ExcelExporter{Dir: dir}).Export("火车票", sheets))
+	f2, err := excelize.OpenFile(filepath.Join(dir, "火车票.xlsx"))
+	require.NoError(t, err)
+	defer f2.Close()
+	rows, err := f2.GetRows("火车票")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"人员", "票价", "原始文件"}, rows[0])
+}
+
+func TestExcelAppend(t *testing.T) {
+	dir := t.TempDir()
+	exporter := &ExcelExporter{Dir: dir, Append: true}
+
+	sheets := testSheets()
+	sheets[0].Keys = []string{"name"}
+	require.NoError(t, exporter.Export("火车票处理结果", sheets))
+
+	// 用户在导出结果中添加了一列并设置了格式
+	filename := filepath.Join(dir, "火车票处理结果.xlsx")
+	f, err := excelize.OpenFile(filename)
+	require.NoError(t, err)
+	require.NoError(t, f.SetCellValue("火车票", "C1", "备注"))
+	require.NoError(t, f.SetCellValue("火车票", "C2", "已报销"))
+	style, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	require.NoError(t, err)
+	require.NoError(t, f.SetCellStyle("火车票", "A3", "B3", style))
+	require.NoError(t, f.SaveAs(filename))
+	require.NoError(t, f.Close())
+
+	sheets[0].Rows = [][]interface{}{
+		{"张三", 600.0},
+		{"王五", 120.0},
+	}
+	require.NoError(t, exporter.Export("火车票处理结果", sheets))
+
+	f, err = excelize.OpenFile(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := f.GetRows("火车票")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"人员", "票价", "备注"},
+		{"张三", "600.00", "已报销"},
+		{"李四", "0.5"},
+		{"王五", "120"},
+	}, rows)
+
+	// 追加的行沿用上一行的格式
+	appendedStyle, err := f.GetCellStyle("火车票", "A4")
+	require.NoError(t, err)
+	assert.Equal(t, style, appendedStyle)
+}
+
+func TestExcelTemplate(t *testing.T) {
+	dir := t.TempDir()
+
+	// 模板第三行为表头，列顺序与导出不同
+	templatePath := filepath.Join(dir, "template.xlsx")
+	f := excelize.NewFile()
+	require.NoError(t, f.SetSheetName("Sheet1", "导入"))
+	require.NoError(t, f.SetCellValue("导入", "A1", "差旅报销导入"))
+	require.NoError(t, f.SetSheetRow("导入", "B3", &[]interface{}{"票价", "人员"}))
+	require.NoError(t, f.SaveAs(templatePath))
+	require.NoError(t, f.Close())
+
+	templates, err := ParseTemplates([]byte("templates:\n  火车票处理结果:\n    path: " + templatePath + "\n    sheet: 导入\n    start_cell: B3\n"))
+	require.NoError(t, err)
+
+	exporter := &ExcelExporter{Dir: dir, Templates: templates}
+	require.NoError(t, exporter.Export("火车票处理结果", testSheets()))
+
+	f, err = excelize.OpenFile(filepath.Join(dir, "火车票处理结果.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := f.GetRows("导入")
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"差旅报销导入"},
+		nil,
+		{"", "票价", "人员"},
+		{"", "553.00", "张三"},
+		{"", "0.50", "李四"},
+	}, rows)
+
+	_, err = ParseTemplates([]byte("templates:\n  x:\n    path: a.xlsx\n    start_cell: 3B\n"))
+	assert.Error(t, err)
+
+	// 工作表名不符合 Excel 的要求时在加载时报错，而不是识别完成后导出时
+	_, err = ParseTemplates([]byte("templates:\n  x:\n    path: a.xlsx\n    sheet: '导入:2024'\n"))
+	assert.ErrorContains(t, err, "template x")
+}
+
+func TestLayout(t *testing.T) {
+	layouts, err := ParseLayouts([]byte(`
+layouts:
+  - name: 财务导入
+    source: 火车票处理结果
+    sheet_name: 导入
+    columns:
+      - {field: date, header: "*出发日期", format: yyyy-mm-dd}
+      - {field: name, header: "*人员"}
+      - {header: 币种, value: CNY}
+      - {field: fare, header: "*票价", format: "0.0"}
+`))
+	require.NoError(t, err)
+	require.Len(t, layouts, 1)
+
+	sheets := []doctype.Sheet{{
+		Name: "火车票",
+		Columns: []doctype.Column{
+			{Key: "name", Header: "人员"},
+			{Key: "date", Header: "出发日期", Type: doctype.ColumnDate},
+			{Key: "fare", Header: "票价", Type: doctype.ColumnMoney},
+		},
+		Rows: [][]interface{}{{"张三", "2024.01.02", "553.00"}},
+		Keys: []string{"name", "date"},
+	}}
+
+	sheet, err := layouts[0].Apply(sheets)
+	require.NoError(t, err)
+	assert.Equal(t, "导入", sheet.Name)
+	assert.Equal(t, []string{"*出发日期", "*人员", "币种", "*票价"}, sheet.Headers())
+	assert.Equal(t, []string{"name", "date"}, sheet.Keys)
+	assert.Equal(t, [][]interface{}{{"2024.01.02", "张三", "CNY", "553.00"}}, sheet.Rows)
+
+	// 来源结果与布局同时导出，格式按布局转换
+	dir := t.TempDir()
+	exporter := WithLayouts(&CSVExporter{Dir: dir}, layouts)
+	require.NoError(t, exporter.Export("火车票处理结果", sheets))
+
+	content, err := os.ReadFile(filepath.Join(dir, "财务导入.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "*出发日期,*人员,币种,*票价\n2024-01-02,张三,CNY,553.0\n", strings.TrimPrefix(string(content), utf8BOM))
+	_, err = os.Stat(filepath.Join(dir, "火车票处理结果.csv"))
+	assert.NoError(t, err)
+
+	// Excel 中日期与金额按格式写入
+	require.NoError(t, WithLayouts(&ExcelExporter{Dir: dir}, layouts).Export("火车票处理结果", sheets))
+	f, err := excelize.OpenFile(filepath.Join(dir, "财务导入.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := f.GetRows("导入")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2024-01-02", "张三", "CNY", "553.0"}, rows[1])
+
+	layouts[0].Columns[0].Field = "unknown"
+	_, err = layouts[0].Apply(sheets)
+	assert.Error(t, err)
+
+	_, err = ParseLayouts([]byte("layouts:\n  - {name: a, source: a, columns: [{field: x}]}\n"))
+	assert.Error(t, err)
+
+	// 表名不符合 Excel 的要求时在加载时报错
+	_, err = ParseLayouts([]byte("layouts:\n  - {name: a, source: b, sheet_name: '2024/04', columns: [{field: x}]}\n"))
+	assert.ErrorContains(t, err, "layout a")
+	_, err = ParseLayouts([]byte("layouts:\n  - {name: a, source: b, sheet_name: 财务共享中心差旅报销凭证导入模板二零二四年第二季度正式对外发布版本, columns: [{field: x}]}\n"))
+	assert.Error(t, err)
+}
+
+func TestGoLayout(t *testing.T) {
+	assert.Equal(t, "2006-01-02", goLayout("yyyy-mm-dd"))
+	assert.Equal(t, "2006/01/02 15:04", goLayout("yyyy/mm/dd hh:mm"))
+	assert.Equal(t, 2, decimals("#,##0.00"))
+}
+
+func TestWorkbookExporterWithLayout(t *testing.T) {
+	dir := t.TempDir()
+	workbook, err := New("", Options{Dir: dir, Workbook: "报销汇总"})
+	require.NoError(t, err)
+	layouts, err := ParseLayouts([]byte(`
+layouts:
+  - name: 财务导入
+    source: 火车票处理结果
+    sheet_name: 导入
+    columns:
+      - {field: name}
+      - {field: start_date}
+      - {field: net_cost}
+`))
+	require.NoError(t, err)
+	exporter := WithLayouts(workbook, layouts)
+
+	train := doctype.Sheet{
+		Name: "火车票",
+		Columns: []doctype.Column{
+			{Key: "name", Header: "人员", Role: doctype.RolePerson},
+			{Key: "start_date", Header: "出发日期", Role: doctype.RoleDate},
+			{Key: "net_cost", Header: "票价", Role: doctype.RoleAmount},
+		},
+		Rows: [][]interface{}{
+			{"张三", "2024.01.02", "553.00"},
+			{"李四", "2024.01.20", "100.50"},
+		},
+	}
+	require.NoError(t, exporter.Export("火车票处理结果", []doctype.Sheet{train}))
+	require.NoError(t, Finish(exporter))
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "报销汇总.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"汇总", "火车票", "导入"}, f.GetSheetList())
+
+	// 布局生成的表与来源表是同一批票据，汇总只统计来源表，合计不重复计算
+	rows, err := f.GetRows("汇总")
+	require.NoError(t, err)
+	assert.Equal(t, "火车票", rows[2][0])
+	assert.Equal(t, "合计", rows[3][0])
+	count, err := f.GetCellFormula("汇总", "B4")
+	require.NoError(t, err)
+	assert.Equal(t, "SUM(B3:B3)", count)
+	total, err := f.CalcCellValue("汇总", "C4")
+	require.NoError(t, err)
+	assert.Equal(t, "653.50", total)
+
+	// 按人员统计同样只引用来源表
+	for _, row := range rows {
+		if len(row) > 1 {
+			assert.NotEqual(t, "导入", row[1])
+		}
+	}
+}
+
+func TestWorkbookExporter(t *testing.T) {
+	dir := t.TempDir()
+	exporter, err := New("", Options{Dir: dir, Workbook: "报销汇总"})
+	require.NoError(t, err)
+
+	train := doctype.Sheet{
+		Name: "火车票",
+		Columns: []doctype.Column{
+			{Key: "name", Header: "人员", Role: doctype.RolePerson},
+			{Key: "start_date", Header: "出发日期", Role: doctype.RoleDate},
+			{Key: "net_cost", Header: "票价", Role: doctype.RoleAmount},
+		},
+		Rows: [][]interface{}{
+			{"张三", "2024.01.02", "553.00"},
+			{"李四", "2024.01.20", "100.50"},
+			{"张三", "2024.02.01", ""},
+		},
+	}
+	vat := doctype.Sheet{
+		Name: "增值税发票",
+		Columns: []doctype.Column{
+			{Key: "date", Header: "开票日期", Role: doctype.RoleDate},
+			{Key: "total_amount", Header: "金额", Role: doctype.RoleAmount},
+			{Key: "tax_rate", Header: "税率", Role: doctype.RoleTaxRate},
+			{Key: "total_tax", Header: "税额", Role: doctype.RoleTax},
+		},
+		Rows: [][]interface{}{
+			{"2024年01月05日", "100.00", "6%", "6.00"},
+			{"2024年02月05日", "200.00", "13%", "26.00"},
+		},
+	}
+	require.NoError(t, exporter.Export("火车票处理结果", []doctype.Sheet{train}))
+	require.NoError(t, exporter.Export("增值税发票处理结果", []doctype.Sheet{vat}))
+	// 不同导出中的同名表不会互相覆盖
+	require.NoError(t, exporter.Export("火车票处理结果2", []doctype.Sheet{train}))
+	require.NoError(t, Finish(exporter))
+
+	f, err := excelize.OpenFile(filepath.Join(dir, "报销汇总.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+	assert.Equal(t, []string{"汇总", "火车票", "增值税发票", "火车票(2)"}, f.GetSheetList())
+
+	rows, err := f.GetRows("汇总")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"类型", "张数", "金额", "税额"}, rows[1])
+	assert.Equal(t, "火车票", rows[2][0])
+
+	formula := func(cell string) string {
+		v, err := f.GetCellFormula("汇总", cell)
+		require.NoError(t, err)
+		return v
+	}
+	assert.Equal(t, "COUNT('火车票'!$C:$C)", formula("B3"))
+	assert.Equal(t, "SUM('火车票'!$C:$C)", formula("C3"))
+	assert.Equal(t, "SUM('增值税发票'!$D:$D)", formula("D4"))
+
+	// 按月份、税率与人员的行由数据中出现的值生成
+	assert.Equal(t, []string{"2024-01", "火车票"}, rows[9][:2])
+	assert.Equal(t, `SUMIFS('火车票'!$C:$C,'火车票'!$B:$B,">="&$A10,'火车票'!$B:$B,"<"&EDATE($A10,1))`, formula("D10"))
+	assert.Equal(t, []string{"13%", "增值税发票"}, rows[19][:2])
+	assert.Equal(t, `SUMIFS('增值税发票'!$D:$D,'增值税发票'!$C:$C,$A20)`, formula("E20"))
+	assert.Equal(t, []string{"张三", "火车票"}, rows[23][:2])
+
+	// 金额按数值写入，公式才能统计
+	amount, err := f.GetCellValue("火车票", "C2", excelize.Options{RawCellValue: true})
+	require.NoError(t, err)
+	assert.Equal(t, "553", amount)
+
+	_, err = New("csv", Options{Workbook: "报销汇总"})
+	assert.Error(t, err)
+}
+
+// bundleSheets 返回一张图片与一份两页 PDF 的发票表，第二行没有原始文件
+func bundleSheets(t *testing.T, dir string) []doctype.Sheet {
+	imagePath := filepath.Join(dir, "receipt.png")
+	file, err := os.Create(imagePath)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(file, image.NewRGBA(image.Rect(0, 0, 300, 600))))
+	require.NoError(t, file.Close())
+
+	pdfPath := filepath.Join(dir, "invoice.pdf")
+	source := fpdf.New("L", "mm", "A5", "")
+	source.AddPage()
+	source.AddPage()
+	require.NoError(t, source.OutputFileAndClose(pdfPath))
+
+	return []doctype.Sheet{{
+		Name: "增值税发票",
+		Columns: []doctype.Column{
+			{Key: "doc_number", Header: "发票号码", Role: doctype.RoleNumber},
+			{Key: "total_amount", Header: "金额", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+			{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+		},
+		Rows: [][]interface{}{
+			{"12345678", "100", pdfPath},
+			{"", "", ""},
+			{"87654321", "20.5", imagePath},
+		},
+	}}
+}
+
+func TestBundleExporter(t *testing.T) {
+	dir := t.TempDir()
+	sheets := bundleSheets(t, dir)
+	imagePath := filepath.Join(dir, "receipt.png")
+
+	items := bundleItems(&sheets[0], nil)
+	require.Len(t, items, 2)
+	assert.Equal(t, bundleItem{sheet: "增值税发票", row: 4, number: "87654321", amount: "20.50", path: imagePath}, items[1])
+
+	// 导出布局生成的表与来源表重复，不打印
+	derived := sheets[0]
+	derived.Derived = true
+	assert.Empty(t, bundleItems(&derived, nil))
+
+	output := filepath.Join(dir, "报销单据.pdf")
+	exporter := Combine(&CSVExporter{Dir: dir}, &BundleExporter{Path: output, Grid: Grid{Columns: 1, Rows: 1}})
+	require.NoError(t, exporter.Export("增值税发票处理结果", sheets))
+	// 没有原始文件列的报表不打印
+	require.NoError(t, exporter.Export("运行报告", testSheets()))
+	require.NoError(t, Finish(exporter))
+
+	// 每页一个格子，PDF 的每一页各占一格
+	sizes, err := pdfPageSizes(output)
+	require.NoError(t, err)
+	assert.Len(t, sizes, 3)
+
+	assert.Equal(t, "1. Row 2  No. 12345678  Amount 100.00", (&BundleExporter{}).header(1, items[0]))
+	assert.Equal(t, "2. 增值税发票 第4行  号码 87654321  金额 20.50", (&BundleExporter{FontFile: "font.ttf"}).header(2, items[1]))
+
+	_, err = pdfPageSizes(imagePath)
+	assert.Error(t, err)
+}
+
+func TestBundleExporterWrittenRows(t *testing.T) {
+	dir := t.TempDir()
+	sheets := bundleSheets(t, dir)
+
+	// 模板第三行为表头，数据从第四行开始写入"导入"工作表
+	templatePath := filepath.Join(dir, "template.xlsx")
+	f := excelize.NewFile()
+	require.NoError(t, f.SetSheetName("Sheet1", "导入"))
+	require.NoError(t, f.SetSheetRow("导入", "B3", &[]interface{}{"金额", "发票号码"}))
+	require.NoError(t, f.SaveAs(templatePath))
+	require.NoError(t, f.Close())
+	templates, err := ParseTemplates([]byte("templates:\n  增值税发票处理结果:\n    path: " + templatePath + "\n    sheet: 导入\n    start_cell: B3\n"))
+	require.NoError(t, err)
+
+	bundle := &BundleExporter{Path: filepath.Join(dir, "报销单据.pdf"), Dir: dir}
+	exporter := Combine(&ExcelExporter{Dir: dir, Templates: templates}, bundle)
+	require.NoError(t, exporter.Export("增值税发票处理结果", sheets))
+	require.NoError(t, Finish(exporter))
+
+	require.Len(t, bundle.items, 2)
+	assert.Equal(t, "导入", bundle.items[0].sheet)
+	assert.Equal(t, []int{4, 6}, []int{bundle.items[0].row, bundle.items[1].row})
+}
+
+func TestBundleExporterAddWorkbook(t *testing.T) {
+	dir := t.TempDir()
+	sheets := bundleSheets(t, dir)
+	require.NoError(t, (&ExcelExporter{Dir: dir}).Export("增值税发票处理结果", sheets))
+
+	// 不经识别，从已导出的工作簿读取；按表头对应列，不需要表名相同
+	known := []doctype.Sheet{testSheets()[0], {Name: "发票", Columns: sheets[0].Columns}}
+	bundle := &BundleExporter{}
+	require.NoError(t, bundle.AddWorkbook(filepath.Join(dir, "增值税发票处理结果.xlsx"), known))
+	require.Len(t, bundle.items, 2)
+	assert.Equal(t, bundleItem{sheet: "增值税发票", row: 2, number: "12345678", amount: "100.00", path: filepath.Join(dir, "invoice.pdf")}, bundle.items[0])
+	assert.Equal(t, 4, bundle.items[1].row)
+
+	assert.Error(t, bundle.AddWorkbook(filepath.Join(dir, "missing.xlsx"), known))
+}
+
+func TestParseGrid(t *testing.T) {
+	grid, err := ParseGrid("3X2")
+	require.NoError(t, err)
+	assert.Equal(t, Grid{Columns: 3, Rows: 2}, grid)
+
+	grid, err = ParseGrid("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultGrid, grid)
+
+	_, err = ParseGrid("0x2")
+	assert.Error(t, err)
+}
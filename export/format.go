@@ -0,0 +1,170 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts 导出值中常见的日期写法
+var dateLayouts = []string{
+	"2006.01.02 15:04",
+	"2006.01.02",
+	"2006.1.2",
+	"2006-01-02",
+	"2006/01/02",
+	"20060102",
+	"2006年01月02日",
+	"2006年1月2日",
+}
+
+// parseDate 解析日期，无法解析时返回 false
+func parseDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseNumber 解析数字，字符串中的货币符号与千分位分隔符会被忽略，百分数转换为小数
+func parseNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		s := strings.NewReplacer("￥", "", "¥", "", ",", "", "，", "", "元", "").Replace(strings.TrimSpace(v))
+		if s == "" {
+			return 0, false
+		}
+		// 百分数转换为小数，如税率"13%"
+		if percent := strings.TrimSuffix(s, "%"); percent != s {
+			f, err := strconv.ParseFloat(percent, 64)
+			return f / 100, err == nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// goLayout 将 Excel 风格的日期格式（yyyy、mm、dd、hh、ss）转换为 Go 的时间格式
+func goLayout(format string) string {
+	// "mm"在"hh"之后表示分钟
+	lower := strings.ToLower(format)
+	var b strings.Builder
+	afterHour := false
+	for i := 0; i < len(lower); {
+		switch {
+		case strings.HasPrefix(lower[i:], "yyyy"):
+			b.WriteString("2006")
+			i += 4
+		case strings.HasPrefix(lower[i:], "yy"):
+			b.WriteString("06")
+			i += 2
+		case strings.HasPrefix(lower[i:], "hh"):
+			b.WriteString("15")
+			afterHour = true
+			i += 2
+		case strings.HasPrefix(lower[i:], "mm"):
+			if afterHour {
+				b.WriteString("04")
+			} else {
+				b.WriteString("01")
+			}
+			i += 2
+		case strings.HasPrefix(lower[i:], "dd"):
+			b.WriteString("02")
+			i += 2
+		case strings.HasPrefix(lower[i:], "ss"):
+			b.WriteString("05")
+			i += 2
+		default:
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// decimals 返回数字格式（如"0.00"、"#,##0.0"）中的小数位数
+func decimals(format string) int {
+	dot := strings.Index(format, ".")
+	if dot == -1 {
+		return 0
+	}
+	count := 0
+	for _, c := range format[dot+1:] {
+		if c != '0' && c != '#' {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// isNumeric 列是否为数值类型
+func isNumeric(column doctype.Column) bool {
+	return column.Type == doctype.ColumnNumber || column.Type == doctype.ColumnMoney
+}
+
+// typedValue 按列的类型与格式转换值，用于 Excel 与 JSON：
+// 设置了格式的日期列转换为 time.Time，数值列转换为 float64，无法转换时保留原值
+func typedValue(column doctype.Column, value interface{}) interface{} {
+	if column.Format == "" {
+		return value
+	}
+	switch {
+	case column.Type == doctype.ColumnDate:
+		if t, ok := parseDate(value); ok {
+			return t
+		}
+	case isNumeric(column):
+		if f, ok := parseNumber(value); ok {
+			return f
+		}
+	}
+	return value
+}
+
+// formatText 按列的格式将值转换为文本，用于 CSV 与 JSON
+func formatText(column doctype.Column, value interface{}) string {
+	switch v := typedValue(column, value).(type) {
+	case time.Time:
+		return v.Format(goLayout(column.Format))
+	case float64:
+		if isNumeric(column) && column.Format != "" {
+			return strconv.FormatFloat(v, 'f', decimals(column.Format), 64)
+		}
+	}
+	return formatValue(value)
+}
+
+// formatValue 将单元格的值转换为文本
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
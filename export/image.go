@@ -0,0 +1,145 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"bytes"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"image"
+	_ "image/jpeg"
+)
+
+// imageMargin 图像与单元格边缘的距离，单位为像素
+const imageMargin = 2
+
+// dropColumns 返回去掉指定列后的表格
+func dropColumns(sheet *doctype.Sheet, drop func(i int) bool) *doctype.Sheet {
+	kept := make([]int, 0, len(sheet.Columns))
+	for i := range sheet.Columns {
+		if !drop(i) {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == len(sheet.Columns) {
+		return sheet
+	}
+
+	result := &doctype.Sheet{Name: sheet.Name, Keys: sheet.Keys, Columns: make([]doctype.Column, 0, len(kept))}
+	for _, i := range kept {
+		result.Columns = append(result.Columns, sheet.Columns[i])
+	}
+	result.Rows = make([][]interface{}, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		values := make([]interface{}, 0, len(kept))
+		for _, i := range kept {
+			if i < len(row) {
+				values = append(values, row[i])
+			} else {
+				values = append(values, nil)
+			}
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result
+}
+
+// withoutImages 去掉图像列，用于不能嵌入图像的格式
+func withoutImages(sheet *doctype.Sheet) *doctype.Sheet {
+	return dropColumns(sheet, func(i int) bool {
+		return sheet.Columns[i].Type == doctype.ColumnImage
+	})
+}
+
+// withoutEmptyImages 去掉没有任何图像的图像列，未启用缩略图时表格中不出现空列
+func withoutEmptyImages(sheet *doctype.Sheet) *doctype.Sheet {
+	return dropColumns(sheet, func(i int) bool {
+		if sheet.Columns[i].Type != doctype.ColumnImage {
+			return false
+		}
+		for _, row := range sheet.Rows {
+			if i < len(row) && len(imageData(row[i])) > 0 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// imageData 返回单元格中的图像数据，不是图像时返回 nil
+func imageData(value interface{}) []byte {
+	data, _ := value.([]byte)
+	return data
+}
+
+// imageSize 返回图像的像素尺寸
+func imageSize(data []byte) (int, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// imageLayout 图像列的列宽与含图像行的行高
+type imageLayout struct {
+	// widths 图像列的列宽，单位为字符宽度
+	widths map[int]float64
+	// rowHeight 含图像的行的行高，单位为磅，没有图像时为 0
+	rowHeight float64
+}
+
+// addImages 将图像嵌入各自的单元格，并按最大的图像计算列宽与行高。
+// 图像点击后打开同一行的原始文件。需要在创建流式写入器之前调用。
+func addImages(f *excelize.File, sheet *doctype.Sheet) (*imageLayout, error) {
+	layout := &imageLayout{widths: make(map[int]float64)}
+
+	link := -1
+	for i, column := range sheet.Columns {
+		if column.Type == doctype.ColumnLink {
+			link = i
+			break
+		}
+	}
+
+	maxHeight := 0
+	for i, column := range sheet.Columns {
+		if column.Type != doctype.ColumnImage {
+			continue
+		}
+		maxWidth := 0
+		for r, row := range sheet.Rows {
+			if i >= len(row) {
+				continue
+			}
+			data := imageData(row[i])
+			if len(data) == 0 {
+				continue
+			}
+			width, height, err := imageSize(data)
+			if err != nil {
+				logger.Warnf("Sheet %s row %d: invalid image: %v", sheet.Name, r+2, err)
+				continue
+			}
+			maxWidth, maxHeight = max(maxWidth, width), max(maxHeight, height)
+
+			options := &excelize.GraphicOptions{OffsetX: imageMargin, OffsetY: imageMargin}
+			if link != -1 && link < len(row) {
+				if path, _ := row[link].(string); path != "" {
+					options.Hyperlink, options.HyperlinkType = fileURL(path), "External"
+					options.AltText = linkText(path)
+				}
+			}
+			cell, _ := excelize.CoordinatesToCellName(i+1, r+2)
+			if err := f.AddPictureFromBytes(sheet.Name, cell, &excelize.Picture{Extension: ".jpg", File: data, Format: options}); err != nil {
+				return nil, fmt.Errorf("failed to add image to %s: %w", cell, err)
+			}
+		}
+		// Excel 的列宽约为 7 像素一个字符
+		layout.widths[i] = float64(maxWidth+2*imageMargin+5) / 7
+	}
+	if maxHeight > 0 {
+		// 行高的单位为磅，1 像素为 0.75 磅
+		layout.rowHeight = float64(maxHeight+2*imageMargin) * 0.75
+	}
+	return layout, nil
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JSONExporter 将表格写入一个 JSON 文件：
+// 只有一张表时为记录数组，多张表时为以表名为键的对象
+type JSONExporter struct {
+	Dir string
+}
+
+func (e *JSONExporter) Export(name string, sheets []doctype.Sheet) error {
+	var content interface{}
+	if len(sheets) == 1 {
+		content = records(withoutImages(&sheets[0]))
+	} else {
+		bySheet := make(map[string][]map[string]interface{}, len(sheets))
+		for i := range sheets {
+			bySheet[sheets[i].Name] = records(withoutImages(&sheets[i]))
+		}
+		content = bySheet
+	}
+
+	data, err := json.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+
+	filename := filepath.Join(e.Dir, name+".json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to save file %s: %w", filename, err)
+	}
+
+	logger.Info("Saved ", filename)
+	return nil
+}
+
+func records(sheet *doctype.Sheet) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(sheet.Rows))
+	for i := range sheet.Rows {
+		result = append(result, formattedRecord(sheet, i))
+	}
+	return result
+}
+
+// formattedRecord 返回第 i 行的记录，设置了格式的日期列转换为格式化的文本，数值列转换为数字
+func formattedRecord(sheet *doctype.Sheet, i int) map[string]interface{} {
+	record := sheet.Record(i)
+	for _, column := range sheet.Columns {
+		if column.Format == "" {
+			continue
+		}
+		key := column.Key
+		if key == "" {
+			key = column.Header
+		}
+		switch v := typedValue(column, record[key]).(type) {
+		case time.Time:
+			record[key] = v.Format(goLayout(column.Format))
+		default:
+			record[key] = v
+		}
+	}
+	return record
+}
+
+// JSONLinesExporter 将每张表写入一个 JSON Lines 文件，每行一条记录
+type JSONLinesExporter struct {
+	Dir string
+}
+
+func (e *JSONLinesExporter) Export(name string, sheets []doctype.Sheet) error {
+	for i := range sheets {
+		filename := sheetFilename(e.Dir, name, sheets, i, ".jsonl")
+		if err := writeJSONLines(filename, withoutImages(&sheets[i])); err != nil {
+			return err
+		}
+		logger.Info("Saved ", filename)
+	}
+	return nil
+}
+
+func writeJSONLines(filename string, sheet *doctype.Sheet) (err error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", filename, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for i := range sheet.Rows {
+		if err := encoder.Encode(formattedRecord(sheet, i)); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+1, err)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// LayoutColumn 布局中的一列
+type LayoutColumn struct {
+	// Field 来源表中列的 Key
+	Field string `yaml:"field"`
+	// Value 固定值，Field 为空时使用
+	Value string `yaml:"value"`
+	// Header 表头文字，为空时沿用来源列的表头
+	Header string `yaml:"header"`
+	// Key JSON 等格式中的字段名，为空时沿用来源列的 Key
+	Key string `yaml:"key"`
+	// Format 数字或日期格式，如"0.00"、"yyyy-mm-dd"
+	Format string `yaml:"format"`
+}
+
+// Layout 在一个导出结果的基础上生成另一种格式的导出，用于对接不同团队的导入要求
+type Layout struct {
+	// Name 导出文件名，不含扩展名
+	Name string `yaml:"name"`
+	// Source 来源导出文件名，不含扩展名
+	Source string `yaml:"source"`
+	// Sheet 来源表名，为空时使用第一张表
+	Sheet string `yaml:"sheet"`
+	// SheetName 导出的表名，为空时沿用来源表名
+	SheetName string         `yaml:"sheet_name"`
+	Columns   []LayoutColumn `yaml:"columns"`
+}
+
+type layoutFile struct {
+	Layouts []Layout `yaml:"layouts"`
+}
+
+// LoadLayouts 读取布局配置文件
+func LoadLayouts(path string) ([]Layout, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout file %s: %w", path, err)
+	}
+	return ParseLayouts(content)
+}
+
+// ParseLayouts 解析布局配置
+func ParseLayouts(content []byte) ([]Layout, error) {
+	var file layoutFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse layouts: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, layout := range file.Layouts {
+		if layout.Name == "" || layout.Source == "" {
+			return nil, fmt.Errorf("layout requires name and source")
+		}
+		if layout.Name == layout.Source {
+			return nil, fmt.Errorf("layout %s: name must differ from source", layout.Name)
+		}
+		if names[layout.Name] {
+			return nil, fmt.Errorf("duplicate layout %s", layout.Name)
+		}
+		names[layout.Name] = true
+		if layout.SheetName != "" {
+			if err := doctype.ValidateSheetName(layout.SheetName); err != nil {
+				return nil, fmt.Errorf("layout %s: %w", layout.Name, err)
+			}
+		}
+		if len(layout.Columns) == 0 {
+			return nil, fmt.Errorf("layout %s: at least one column is required", layout.Name)
+		}
+		for _, column := range layout.Columns {
+			if column.Field == "" && column.Header == "" {
+				return nil, fmt.Errorf("layout %s: column with a fixed value requires header", layout.Name)
+			}
+		}
+	}
+	return file.Layouts, nil
+}
+
+// Apply 按布局从来源表格生成导出表
+func (l *Layout) Apply(sheets []doctype.Sheet) (doctype.Sheet, error) {
+	var source *doctype.Sheet
+	for i := range sheets {
+		if l.Sheet == "" || sheets[i].Name == l.Sheet {
+			source = &sheets[i]
+			break
+		}
+	}
+	if source == nil {
+		return doctype.Sheet{}, fmt.Errorf("layout %s: sheet %s not found in %s", l.Name, l.Sheet, l.Source)
+	}
+
+	indexes := make(map[string]int, len(source.Columns))
+	for i, column := range source.Columns {
+		indexes[column.Key] = i
+	}
+
+	sheet := doctype.Sheet{Name: l.SheetName, Columns: make([]doctype.Column, 0, len(l.Columns)), Derived: true}
+	if sheet.Name == "" {
+		sheet.Name = source.Name
+	}
+
+	// 每一列在来源表中的位置，固定值的列为 -1
+	positions := make([]int, 0, len(l.Columns))
+	for _, c := range l.Columns {
+		column := doctype.Column{Key: c.Key, Header: c.Header, Format: c.Format}
+		position := -1
+		if c.Field != "" {
+			i, ok := indexes[c.Field]
+			if !ok {
+				return doctype.Sheet{}, fmt.Errorf("layout %s: unknown field %s in %s", l.Name, c.Field, source.Name)
+			}
+			position = i
+			column.Type = source.Columns[i].Type
+			column.Role = source.Columns[i].Role
+			if column.Key == "" {
+				column.Key = source.Columns[i].Key
+			}
+			if column.Header == "" {
+				column.Header = source.Columns[i].Header
+			}
+		}
+		sheet.Columns = append(sheet.Columns, column)
+		positions = append(positions, position)
+	}
+
+	// 布局包含来源表的全部键列时才保留键，部分键列不足以区分不同的行
+	for _, key := range source.Keys {
+		found := false
+		for j, c := range l.Columns {
+			if c.Field == key {
+				sheet.Keys = append(sheet.Keys, sheet.Columns[j].Key)
+				found = true
+				break
+			}
+		}
+		if !found {
+			sheet.Keys = nil
+			break
+		}
+	}
+
+	sheet.Rows = make([][]interface{}, 0, len(source.Rows))
+	for _, sourceRow := range source.Rows {
+		row := make([]interface{}, len(positions))
+		for j, position := range positions {
+			switch {
+			case position == -1:
+				row[j] = l.Columns[j].Value
+			case position < len(sourceRow):
+				row[j] = sourceRow[position]
+			}
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+	return sheet, nil
+}
+
+// layoutExporter 在导出每个结果后，按来源为该结果的布局生成额外的导出
+type layoutExporter struct {
+	Exporter
+	layouts []Layout
+}
+
+// WithLayouts 为导出器附加布局，没有布局时返回原导出器
+func WithLayouts(exporter Exporter, layouts []Layout) Exporter {
+	if len(layouts) == 0 {
+		return exporter
+	}
+	return &layoutExporter{Exporter: exporter, layouts: layouts}
+}
+
+func (e *layoutExporter) Export(name string, sheets []doctype.Sheet) error {
+	if err := e.Exporter.Export(name, sheets); err != nil {
+		return err
+	}
+
+	for i := range e.layouts {
+		layout := &e.layouts[i]
+		if layout.Source != name {
+			continue
+		}
+		sheet, err := layout.Apply(sheets)
+		if err != nil {
+			return err
+		}
+		if err := e.Exporter.Export(layout.Name, []doctype.Sheet{sheet}); err != nil {
+			return fmt.Errorf("layout %s: %w", layout.Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *layoutExporter) Finish() error {
+	return Finish(e.Exporter)
+}
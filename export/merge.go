@@ -0,0 +1,242 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"strings"
+)
+
+// mergeSheet 将表格合并到工作簿中的工作表，表头位于 startCell 所在行。
+// 按表头文字对应列，原表中没有的列追加到表头末尾，用户添加的列与单元格格式保持不变；
+// 表格设置了 Keys 时，键相同的行就地更新，其余行追加到最后一行之后并沿用上一行的格式；
+// 列设置了格式且单元格没有格式时按列的格式写入，金额与日期列没有设置格式时使用默认格式；不导出图像列。
+func mergeSheet(f *excelize.File, sheetName, startCell string, sheet *doctype.Sheet) error {
+	// 合并时不嵌入缩略图，已有行的图像无法随行更新
+	sheet = withoutImages(sheet)
+	columns := excelColumns(sheet.Columns)
+	startCol, headerRow, err := excelize.CellNameToCoordinates(startCell)
+	if err != nil {
+		return fmt.Errorf("invalid start cell %s: %w", startCell, err)
+	}
+
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+
+	positions, err := mergeHeaders(f, sheetName, startCol, headerRow, rows, sheet)
+	if err != nil {
+		return err
+	}
+
+	keyIndexes := make([]int, 0, len(sheet.Keys))
+	for _, key := range sheet.Keys {
+		for i, column := range sheet.Columns {
+			if column.Key == key {
+				keyIndexes = append(keyIndexes, i)
+			}
+		}
+	}
+
+	// 索引原有的数据行
+	byKey := make(map[string]int)
+	lastRow := headerRow
+	for r := headerRow + 1; r <= len(rows); r++ {
+		row := rows[r-1]
+		if isBlankRow(row, startCol) {
+			continue
+		}
+		lastRow = r
+		key := rowKey(keyIndexes, func(i int) string {
+			if col := positions[i]; col <= len(row) {
+				return row[col-1]
+			}
+			return ""
+		})
+		if key != "" {
+			byKey[key] = r
+		}
+	}
+
+	styles := newNumberStyles(f)
+	updated, appended := 0, 0
+	for _, values := range sheet.Rows {
+		key := rowKey(keyIndexes, func(i int) string {
+			if i < len(values) {
+				return formatText(columns[i], values[i])
+			}
+			return ""
+		})
+
+		target, exists := byKey[key]
+		if key == "" || !exists {
+			lastRow++
+			target = lastRow
+			if target-1 > headerRow {
+				if err := copyRowStyle(f, sheetName, positions, target-1, target); err != nil {
+					return err
+				}
+			}
+			if key != "" {
+				byKey[key] = target
+			}
+			appended++
+		} else {
+			updated++
+		}
+
+		for i, value := range values {
+			if i >= len(positions) {
+				break
+			}
+			cell, _ := excelize.CoordinatesToCellName(positions[i], target)
+			column := columns[i]
+			if column.Type == doctype.ColumnLink {
+				if err := writeLink(f, sheetName, cell, value); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := f.SetCellValue(sheetName, cell, typedValue(column, value)); err != nil {
+				return fmt.Errorf("failed to write %s: %w", cell, err)
+			}
+			if column.Format != "" {
+				if err := applyFormat(f, styles, sheetName, cell, column.Format); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	logger.Infof("Sheet %s: %d rows updated, %d rows appended", sheetName, updated, appended)
+	return nil
+}
+
+// mergeHeaders 返回每一列在工作表中的列号，并写入原表中没有的表头
+func mergeHeaders(f *excelize.File, sheetName string, startCol, headerRow int, rows [][]string, sheet *doctype.Sheet) ([]int, error) {
+	var header []string
+	if headerRow <= len(rows) {
+		header = rows[headerRow-1]
+	}
+
+	existing := make(map[string]int)
+	lastCol := startCol - 1
+	for col := startCol; col <= len(header); col++ {
+		if text := strings.TrimSpace(header[col-1]); text != "" {
+			existing[text] = col
+			lastCol = col
+		}
+	}
+
+	// 新增的表头沿用最后一个表头的格式
+	var headerStyle int
+	if lastCol >= startCol {
+		cell, _ := excelize.CoordinatesToCellName(lastCol, headerRow)
+		style, err := f.GetCellStyle(sheetName, cell)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read style of %s: %w", cell, err)
+		}
+		headerStyle = style
+	}
+
+	positions := make([]int, len(sheet.Columns))
+	for i, column := range sheet.Columns {
+		if col, ok := existing[column.Header]; ok {
+			positions[i] = col
+			continue
+		}
+
+		lastCol++
+		positions[i] = lastCol
+		cell, _ := excelize.CoordinatesToCellName(lastCol, headerRow)
+		if err := f.SetCellValue(sheetName, cell, column.Header); err != nil {
+			return nil, fmt.Errorf("failed to write header %s: %w", cell, err)
+		}
+		if headerStyle != 0 {
+			if err := f.SetCellStyle(sheetName, cell, cell, headerStyle); err != nil {
+				return nil, fmt.Errorf("failed to set style of %s: %w", cell, err)
+			}
+		}
+	}
+	return positions, nil
+}
+
+// writeLink 写入指向原始文件的超链接
+func writeLink(f *excelize.File, sheetName, cell string, value interface{}) error {
+	path, _ := value.(string)
+	if err := f.SetCellValue(sheetName, cell, linkText(path)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cell, err)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := f.SetCellHyperLink(sheetName, cell, fileURL(path), "External"); err != nil {
+		return fmt.Errorf("failed to set hyperlink of %s: %w", cell, err)
+	}
+	return nil
+}
+
+// applyFormat 为没有格式的单元格设置数字格式，保留用户设置的格式
+func applyFormat(f *excelize.File, styles *numberStyles, sheetName, cell, format string) error {
+	current, err := f.GetCellStyle(sheetName, cell)
+	if err != nil {
+		return fmt.Errorf("failed to read style of %s: %w", cell, err)
+	}
+	if current != 0 {
+		return nil
+	}
+	id, err := styles.get(format)
+	if err != nil {
+		return err
+	}
+	return f.SetCellStyle(sheetName, cell, cell, id)
+}
+
+// copyRowStyle 将 from 行中各列的格式复制到 to 行
+func copyRowStyle(f *excelize.File, sheetName string, positions []int, from, to int) error {
+	for _, col := range positions {
+		source, _ := excelize.CoordinatesToCellName(col, from)
+		style, err := f.GetCellStyle(sheetName, source)
+		if err != nil {
+			return fmt.Errorf("failed to read style of %s: %w", source, err)
+		}
+		if style == 0 {
+			continue
+		}
+		target, _ := excelize.CoordinatesToCellName(col, to)
+		if err := f.SetCellStyle(sheetName, target, target, style); err != nil {
+			return fmt.Errorf("failed to set style of %s: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// rowKey 拼接键列的值，键列全部为空时返回空字符串
+func rowKey(keyIndexes []int, value func(i int) string) string {
+	if len(keyIndexes) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(keyIndexes))
+	empty := true
+	for _, i := range keyIndexes {
+		v := strings.TrimSpace(value(i))
+		if v != "" {
+			empty = false
+		}
+		parts = append(parts, v)
+	}
+	if empty {
+		return ""
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func isBlankRow(row []string, startCol int) bool {
+	for col := startCol; col <= len(row); col++ {
+		if strings.TrimSpace(row[col-1]) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// 列宽范围，单位为字符宽度
+const (
+	minColumnWidth = 6
+	maxColumnWidth = 50
+)
+
+// excelColumns 为没有设置格式的金额与日期列补充默认格式，使其在 Excel 中按数值与日期写入
+func excelColumns(columns []doctype.Column) []doctype.Column {
+	result := make([]doctype.Column, len(columns))
+	for i, column := range columns {
+		if column.Format == "" {
+			switch column.Type {
+			case doctype.ColumnMoney:
+				column.Format = "0.00"
+			case doctype.ColumnDate:
+				column.Format = "yyyy-mm-dd"
+			}
+		}
+		result[i] = column
+	}
+	return result
+}
+
+// headerStyle 表头样式：加粗、浅蓝底色、下边框
+func headerStyle(f *excelize.File) (int, error) {
+	return f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"DDEBF7"}, Pattern: 1},
+		Border:    []excelize.Border{{Type: "bottom", Color: "9BC2E6", Style: 1}},
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	})
+}
+
+// linkStyle 超链接样式
+func linkStyle(f *excelize.File) (int, error) {
+	return f.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "0563C1", Underline: "single"}})
+}
+
+// highlightWarnings 为需要复核的列设置条件格式，值不为空时整行以浅红色高亮
+func highlightWarnings(f *excelize.File, sheetName string, columns []doctype.Column, lastRow int) error {
+	if lastRow < 2 {
+		return nil
+	}
+	lastCol, _ := excelize.ColumnNumberToName(len(columns))
+	rangeRef := fmt.Sprintf("A2:%s%d", lastCol, lastRow)
+
+	var styleID *int
+	for i, column := range columns {
+		if !column.Warning {
+			continue
+		}
+		if styleID == nil {
+			id, err := f.NewConditionalStyle(&excelize.Style{
+				Font: &excelize.Font{Color: "9C0006"},
+				Fill: excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create warning style: %w", err)
+			}
+			styleID = &id
+		}
+		name, _ := excelize.ColumnNumberToName(i + 1)
+		if err := f.SetConditionalFormat(sheetName, rangeRef, []excelize.ConditionalFormatOptions{
+			{Type: "formula", Criteria: fmt.Sprintf("LEN($%s2)>0", name), Format: styleID},
+		}); err != nil {
+			return fmt.Errorf("failed to highlight column %s: %w", column.Header, err)
+		}
+	}
+	return nil
+}
+
+// columnWidths 按表头与各行文本的显示宽度估算列宽
+func columnWidths(columns []doctype.Column, rows [][]interface{}) []float64 {
+	widths := make([]float64, len(columns))
+	for i, column := range columns {
+		width := displayWidth(column.Header)
+		for _, row := range rows {
+			if i >= len(row) {
+				continue
+			}
+			var text string
+			switch column.Type {
+			case doctype.ColumnLink:
+				text = linkText(row[i])
+			case doctype.ColumnImage:
+				// 图像列的宽度按图像尺寸计算
+			default:
+				text = formatText(column, row[i])
+			}
+			width = max(width, displayWidth(text))
+		}
+		widths[i] = float64(min(max(width+2, minColumnWidth), maxColumnWidth))
+	}
+	return widths
+}
+
+// displayWidth 文本的显示宽度，中文等全角字符按两个字符计算
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if r < utf8.RuneSelf {
+			width++
+		} else {
+			width += 2
+		}
+	}
+	return width
+}
+
+// linkText 超链接显示的文字，即文件名
+func linkText(value interface{}) string {
+	path, _ := value.(string)
+	if path == "" {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// hyperlinkFormula 返回打开文件的 HYPERLINK 公式，流式写入时无法设置单元格超链接
+func hyperlinkFormula(path string) string {
+	escape := func(s string) string { return strings.ReplaceAll(s, `"`, `""`) }
+	return fmt.Sprintf(`HYPERLINK("%s","%s")`, escape(fileURL(path)), escape(linkText(path)))
+}
+
+// fileURL 将本地路径转换为 file URL，Windows 路径转换为 file:///C:/...
+func fileURL(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// localPath 将 fileURL 生成的 file URL 转换回本地路径，不是 file URL 时返回空字符串
+func localPath(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	p := u.Path
+	// Windows 路径 /C:/... 去掉开头的斜杠
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+// Template 用户提供的 Excel 模板，导出结果的第一张表写入模板中的指定工作表
+type Template struct {
+	// Path 模板文件路径
+	Path string `yaml:"path"`
+	// Sheet 写入的工作表，为空时使用导出表的名称
+	Sheet string `yaml:"sheet"`
+	// StartCell 表头所在的单元格，数据从下一行开始，为空时为 A1。
+	// 模板中该行已有表头时按表头文字对应列，否则写入表头
+	StartCell string `yaml:"start_cell"`
+}
+
+// templateFile 模板配置文件，按导出文件名（不含扩展名）指定模板
+type templateFile struct {
+	Templates map[string]Template `yaml:"templates"`
+}
+
+// LoadTemplates 读取模板配置文件
+func LoadTemplates(path string) (map[string]Template, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file %s: %w", path, err)
+	}
+	return ParseTemplates(content)
+}
+
+// ParseTemplates 解析模板配置并检查模板文件、工作表名与起始单元格
+func ParseTemplates(content []byte) (map[string]Template, error) {
+	var file templateFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	for name, template := range file.Templates {
+		if template.Path == "" {
+			return nil, fmt.Errorf("template %s: path is required", name)
+		}
+		if template.Sheet != "" {
+			if err := doctype.ValidateSheetName(template.Sheet); err != nil {
+				return nil, fmt.Errorf("template %s: %w", name, err)
+			}
+		}
+		if template.StartCell == "" {
+			template.StartCell = "A1"
+			file.Templates[name] = template
+		}
+		if _, _, err := excelize.CellNameToCoordinates(template.StartCell); err != nil {
+			return nil, fmt.Errorf("template %s: invalid start_cell: %w", name, err)
+		}
+	}
+	return file.Templates, nil
+}
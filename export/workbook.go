@@ -0,0 +1,449 @@
+package export
+
+import (
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// summarySheetName 合并工作簿中汇总表的名称
+const summarySheetName = "汇总"
+
+// WorkbookExporter 将一次运行的所有导出结果写入一个工作簿：每张表一个工作表，另加一张汇总表。
+// 汇总表使用引用各工作表的公式，修改明细后汇总随之更新。
+type WorkbookExporter struct {
+	Dir string
+	// Name 工作簿文件名，不含扩展名
+	Name   string
+	sheets []doctype.Sheet
+}
+
+// Export 收集表格，在 Finish 时统一写入
+func (e *WorkbookExporter) Export(name string, sheets []doctype.Sheet) error {
+	e.sheets = append(e.sheets, sheets...)
+	return nil
+}
+
+// Finish 写入工作簿
+func (e *WorkbookExporter) Finish() (err error) {
+	if len(e.sheets) == 0 {
+		return nil
+	}
+
+	f := excelize.NewFile()
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err := f.SetSheetName("Sheet1", summarySheetName); err != nil {
+		return fmt.Errorf("failed to rename sheet: %w", err)
+	}
+
+	used := map[string]bool{summarySheetName: true}
+	written := make([]doctype.Sheet, 0, len(e.sheets))
+	for _, sheet := range e.sheets {
+		// 先去掉空的图像列，汇总公式引用的列号与写入的工作表一致
+		sheet = workbookSheet(*withoutEmptyImages(&sheet))
+		sheet.Name = uniqueSheetName(used, sheet.Name)
+		if _, err := f.NewSheet(sheet.Name); err != nil {
+			return fmt.Errorf("failed to create sheet %s: %w", sheet.Name, err)
+		}
+		if err := writeSheet(f, &sheet); err != nil {
+			return fmt.Errorf("sheet %s: %w", sheet.Name, err)
+		}
+		written = append(written, sheet)
+	}
+
+	if err := writeSummary(f, written); err != nil {
+		return fmt.Errorf("sheet %s: %w", summarySheetName, err)
+	}
+
+	filename := filepath.Join(e.Dir, e.Name+".xlsx")
+	if err := f.SaveAs(filename); err != nil {
+		return fmt.Errorf("failed to save file %s: %w", filename, err)
+	}
+
+	logger.Infof("Saved %d sheets to %s", len(written), filename)
+	return nil
+}
+
+// workbookSheet 按列的含义设置类型与格式，使金额、税率与日期以数值写入，公式才能统计；
+// 空字符串写为空单元格，避免被计入张数
+func workbookSheet(sheet doctype.Sheet) doctype.Sheet {
+	columns := make([]doctype.Column, len(sheet.Columns))
+	for i, column := range sheet.Columns {
+		switch column.Role {
+		case doctype.RoleAmount, doctype.RoleTax:
+			column.Type = doctype.ColumnMoney
+		case doctype.RoleTaxRate:
+			column.Type = doctype.ColumnNumber
+			if column.Format == "" {
+				column.Format = "0%"
+			}
+		case doctype.RoleDate:
+			column.Type = doctype.ColumnDate
+		}
+		columns[i] = column
+	}
+
+	rows := make([][]interface{}, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		rows[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+				continue
+			}
+			rows[i][j] = value
+		}
+	}
+
+	sheet.Columns = columns
+	sheet.Rows = rows
+	return sheet
+}
+
+// uniqueSheetName 返回合法且不重复的工作表名：去除 Excel 不允许的字符，截断到 31 个字符，重名时加序号
+func uniqueSheetName(used map[string]bool, name string) string {
+	name = strings.NewReplacer(":", "", "\\", "", "/", "", "?", "", "*", "", "[", "", "]", "").Replace(name)
+	if name == "" {
+		name = "Sheet"
+	}
+	candidate := truncateRunes(name, 31)
+	for i := 2; used[candidate]; i++ {
+		suffix := fmt.Sprintf("(%d)", i)
+		candidate = truncateRunes(name, 31-len(suffix)) + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// summarySource 参与汇总的工作表及其各含义列的列名（如"G"），没有该列时为空
+type summarySource struct {
+	sheet                        *doctype.Sheet
+	amount, tax, rate, date, who string
+	amountIndex, rateIndex       int
+	dateIndex, personIndex       int
+}
+
+// ref 返回整列引用，新增或删除行后公式仍然有效
+func (s *summarySource) ref(column string) string {
+	return fmt.Sprintf("'%s'!$%s:$%s", strings.ReplaceAll(s.sheet.Name, "'", "''"), column, column)
+}
+
+func summarySources(sheets []doctype.Sheet) []*summarySource {
+	sources := make([]*summarySource, 0)
+	for i := range sheets {
+		// 布局生成的表与来源表的行相同，只统计来源表
+		if sheets[i].Derived {
+			continue
+		}
+		source := &summarySource{sheet: &sheets[i], amountIndex: -1, rateIndex: -1, dateIndex: -1, personIndex: -1}
+		for j, column := range sheets[i].Columns {
+			name, _ := excelize.ColumnNumberToName(j + 1)
+			switch column.Role {
+			case doctype.RoleAmount:
+				source.amount, source.amountIndex = name, j
+			case doctype.RoleTax:
+				source.tax = name
+			case doctype.RoleTaxRate:
+				source.rate, source.rateIndex = name, j
+			case doctype.RoleDate:
+				source.date, source.dateIndex = name, j
+			case doctype.RolePerson:
+				source.who, source.personIndex = name, j
+			}
+		}
+		// 没有金额列的表（如商品明细、报表）不参与汇总
+		if source.amount != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// summaryWriter 逐行写入汇总表
+type summaryWriter struct {
+	f   *excelize.File
+	row int
+}
+
+func (w *summaryWriter) write(values ...interface{}) error {
+	w.row++
+	for i, value := range values {
+		cell, _ := excelize.CoordinatesToCellName(i+1, w.row)
+		var err error
+		if formula, ok := value.(formula); ok {
+			err = w.f.SetCellFormula(summarySheetName, cell, string(formula))
+		} else {
+			err = w.f.SetCellValue(summarySheetName, cell, value)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", cell, err)
+		}
+	}
+	return nil
+}
+
+// style 为当前行第 col 列（从 1 开始）设置格式
+func (w *summaryWriter) style(col int, styleID int) error {
+	cell, _ := excelize.CoordinatesToCellName(col, w.row)
+	return w.f.SetCellStyle(summarySheetName, cell, cell, styleID)
+}
+
+// formula 汇总表中的公式
+type formula string
+
+// writeSummary 写入汇总表：按票据类型、月份、税率与人员统计张数与金额
+func writeSummary(f *excelize.File, sheets []doctype.Sheet) error {
+	sources := summarySources(sheets)
+	styles := newNumberStyles(f)
+	moneyStyle, err := styles.get("0.00")
+	if err != nil {
+		return err
+	}
+	w := &summaryWriter{f: f}
+
+	// 按票据类型，各部分的张数均为填写了金额的票据数
+	if err := w.write("按票据类型"); err != nil {
+		return err
+	}
+	if err := w.write("类型", "张数", "金额", "税额"); err != nil {
+		return err
+	}
+	first := w.row + 1
+	for _, s := range sources {
+		tax := interface{}("")
+		if s.tax != "" {
+			tax = formula(fmt.Sprintf("SUM(%s)", s.ref(s.tax)))
+		}
+		if err := w.write(s.sheet.Name,
+			formula(fmt.Sprintf("COUNT(%s)", s.ref(s.amount))),
+			formula(fmt.Sprintf("SUM(%s)", s.ref(s.amount))),
+			tax,
+		); err != nil {
+			return err
+		}
+		if err := w.styleMoney(moneyStyle, 3, 4); err != nil {
+			return err
+		}
+	}
+	last := w.row
+	if err := w.write("合计",
+		formula(fmt.Sprintf("SUM(B%d:B%d)", first, last)),
+		formula(fmt.Sprintf("SUM(C%d:C%d)", first, last)),
+		formula(fmt.Sprintf("SUM(D%d:D%d)", first, last)),
+	); err != nil {
+		return err
+	}
+	if err := w.styleMoney(moneyStyle, 3, 4); err != nil {
+		return err
+	}
+
+	if err := writeMonthSummary(w, sources, styles, moneyStyle); err != nil {
+		return err
+	}
+	if err := writeRateSummary(w, sources, styles, moneyStyle); err != nil {
+		return err
+	}
+	return writePersonSummary(w, sources, moneyStyle)
+}
+
+func (w *summaryWriter) styleMoney(styleID int, cols ...int) error {
+	for _, col := range cols {
+		if err := w.style(col, styleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMonthSummary 按月份统计，月份为当月第一天，统计范围为当月第一天至下月第一天之前
+func writeMonthSummary(w *summaryWriter, sources []*summarySource, styles *numberStyles, moneyStyle int) error {
+	monthStyle, err := styles.get("yyyy-mm")
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		month  time.Time
+		source *summarySource
+	}
+	seen := make(map[entry]bool)
+	var entries []entry
+	for _, s := range sources {
+		if s.date == "" {
+			continue
+		}
+		for _, row := range s.sheet.Rows {
+			t, ok := parseDate(row[s.dateIndex])
+			if !ok {
+				continue
+			}
+			e := entry{month: time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), source: s}
+			if !seen[e] {
+				seen[e] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].month.Before(entries[j].month) })
+
+	w.row++
+	if err := w.write("按月份"); err != nil {
+		return err
+	}
+	if err := w.write("月份", "类型", "张数", "金额"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s := e.source
+		criteria := fmt.Sprintf(`%s,">="&$A%d,%s,"<"&EDATE($A%d,1)`, s.ref(s.date), w.row+1, s.ref(s.date), w.row+1)
+		if err := w.write(e.month, s.sheet.Name,
+			formula(fmt.Sprintf(`COUNTIFS(%s,"<>",%s)`, s.ref(s.amount), criteria)),
+			formula(fmt.Sprintf("SUMIFS(%s,%s)", s.ref(s.amount), criteria)),
+		); err != nil {
+			return err
+		}
+		if err := w.style(1, monthStyle); err != nil {
+			return err
+		}
+		if err := w.style(4, moneyStyle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRateSummary 按税率统计，只包含有税率列的票据
+func writeRateSummary(w *summaryWriter, sources []*summarySource, styles *numberStyles, moneyStyle int) error {
+	rateStyle, err := styles.get("0%")
+	if err != nil {
+		return err
+	}
+
+	type entry struct {
+		rate   float64
+		source *summarySource
+	}
+	seen := make(map[entry]bool)
+	var entries []entry
+	for _, s := range sources {
+		if s.rate == "" {
+			continue
+		}
+		for _, row := range s.sheet.Rows {
+			rate, ok := parseNumber(row[s.rateIndex])
+			if !ok {
+				continue
+			}
+			e := entry{rate: rate, source: s}
+			if !seen[e] {
+				seen[e] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].rate < entries[j].rate })
+
+	w.row++
+	if err := w.write("按税率"); err != nil {
+		return err
+	}
+	if err := w.write("税率", "类型", "张数", "金额", "税额"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s := e.source
+		criteria := fmt.Sprintf("%s,$A%d", s.ref(s.rate), w.row+1)
+		tax := interface{}("")
+		if s.tax != "" {
+			tax = formula(fmt.Sprintf("SUMIFS(%s,%s)", s.ref(s.tax), criteria))
+		}
+		if err := w.write(e.rate, s.sheet.Name,
+			formula(fmt.Sprintf(`COUNTIFS(%s,"<>",%s)`, s.ref(s.amount), criteria)),
+			formula(fmt.Sprintf("SUMIFS(%s,%s)", s.ref(s.amount), criteria)),
+			tax,
+		); err != nil {
+			return err
+		}
+		if err := w.style(1, rateStyle); err != nil {
+			return err
+		}
+		if err := w.styleMoney(moneyStyle, 4, 5); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePersonSummary 按人员统计，只包含有人员列的票据
+func writePersonSummary(w *summaryWriter, sources []*summarySource, moneyStyle int) error {
+	type entry struct {
+		person string
+		source *summarySource
+	}
+	seen := make(map[entry]bool)
+	var entries []entry
+	for _, s := range sources {
+		if s.who == "" {
+			continue
+		}
+		for _, row := range s.sheet.Rows {
+			person, _ := row[s.personIndex].(string)
+			if person == "" {
+				continue
+			}
+			e := entry{person: person, source: s}
+			if !seen[e] {
+				seen[e] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].person < entries[j].person })
+
+	w.row++
+	if err := w.write("按人员"); err != nil {
+		return err
+	}
+	if err := w.write("人员", "类型", "张数", "金额"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		s := e.source
+		criteria := fmt.Sprintf("%s,$A%d", s.ref(s.who), w.row+1)
+		if err := w.write(e.person, s.sheet.Name,
+			formula(fmt.Sprintf(`COUNTIFS(%s,"<>",%s)`, s.ref(s.amount), criteria)),
+			formula(fmt.Sprintf("SUMIFS(%s,%s)", s.ref(s.amount), criteria)),
+		); err != nil {
+			return err
+		}
+		if err := w.style(4, moneyStyle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
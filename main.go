@@ -1,20 +1,44 @@
 package main
 
 import (
+	"FinDocOCR/compliance"
 	"FinDocOCR/config"
+	"FinDocOCR/dedup"
 	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/export"
 	"FinDocOCR/proc"
-	"FinDocOCR/proc/invoice/vat"
+	"FinDocOCR/proc/mapping"
 	"FinDocOCR/proc/ticket/train"
+	"FinDocOCR/proc/ticket/train/station"
+	"FinDocOCR/runreport"
+	"FinDocOCR/store"
+	"FinDocOCR/tax"
+	"FinDocOCR/travel"
 	"FinDocOCR/utils"
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"github.com/carlmjohnson/requests"
 	_ "github.com/joho/godotenv/autoload"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	// 注册内置的票据类型
+	_ "FinDocOCR/proc/invoice/general"
+	_ "FinDocOCR/proc/invoice/vat"
+	_ "FinDocOCR/proc/other"
+	_ "FinDocOCR/proc/receipt/shopping"
+	_ "FinDocOCR/proc/ticket/air"
+	_ "FinDocOCR/proc/ticket/passenger"
 )
 
+// provider 识别服务，记录在处理记录中
+const provider = "baidu"
+
 type AccessResponseBody struct {
 	RefreshToken  string `json:"refresh_token"`
 	ExpiresIn     int    `json:"expires_in"`
@@ -44,8 +68,110 @@ func main() {
 	BaiduClientId := os.Getenv("BAIDU_CLIENT_ID")
 	BaiduClientSecret := os.Getenv("BAIDU_CLIENT_SECRET")
 	docDir := os.Getenv("DOC_DIR")
+	docTypeDir := os.Getenv("DOC_TYPE_DIR")
+	if docTypeDir == "" {
+		docTypeDir = "doctypes"
+	}
 
 	logger := config.GetLogger()
+
+	// bundle 子命令从已导出的工作簿重新生成打印用的票据合集，不需要识别
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		rebuildBundle(os.Args[2:], docTypeDir)
+		return
+	}
+
+	// 导出格式与目录，命令行参数优先于环境变量
+	exportFormat := flag.String("format", os.Getenv("EXPORT_FORMAT"), "export format: xlsx, csv, json or jsonl")
+	exportDir := flag.String("out", os.Getenv("EXPORT_DIR"), "directory to write exported files to")
+	exportAppend := flag.Bool("append", os.Getenv("EXPORT_APPEND") == "true", "merge into existing workbooks instead of overwriting them")
+	exportThumbnails := flag.Bool("thumbnails", os.Getenv("EXPORT_THUMBNAILS") == "true", "embed a thumbnail of each source image in Excel exports")
+	exportWorkbook := flag.String("workbook", os.Getenv("EXPORT_WORKBOOK"), "write all exports into one workbook with this name, with a summary sheet")
+	exportBundle := flag.String("bundle", os.Getenv("EXPORT_BUNDLE"), "compose all source documents into a printable PDF with this name")
+	bundleGrid := flag.String("bundle-grid", os.Getenv("BUNDLE_GRID"), "documents per page of the printable PDF, columns x rows such as 2x2")
+	flag.Parse()
+
+	exportOptions := export.Options{Dir: *exportDir, Append: *exportAppend, Workbook: *exportWorkbook}
+	if templateFile := os.Getenv("EXCEL_TEMPLATE_FILE"); templateFile != "" {
+		templates, err := export.LoadTemplates(templateFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		exportOptions.Templates = templates
+	}
+	exporter, err := export.New(*exportFormat, exportOptions)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+	// 各团队自定义的导入格式
+	if layoutFile := os.Getenv("EXPORT_LAYOUT_FILE"); layoutFile != "" {
+		layouts, err := export.LoadLayouts(layoutFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		exporter = export.WithLayouts(exporter, layouts)
+	}
+	// 打印用的票据合集，不包含自定义导入格式中重复的行
+	if *exportBundle != "" {
+		grid, err := export.ParseGrid(*bundleGrid)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		bundle := &export.BundleExporter{
+			Path:     filepath.Join(*exportDir, *exportBundle+".pdf"),
+			Grid:     grid,
+			FontFile: os.Getenv("BUNDLE_FONT_FILE"),
+		}
+		// 以 Excel 导出时，行号取自追加或写入模板后最终的工作表
+		if export.IsExcel(*exportFormat) {
+			bundle.Dir, bundle.Workbook = *exportDir, *exportWorkbook
+			if bundle.Dir == "" {
+				bundle.Dir = "."
+			}
+		}
+		exporter = export.Combine(exporter, bundle)
+	}
+	if *exportDir != "" {
+		if err := os.MkdirAll(*exportDir, 0755); err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 加载以配置文件声明的票据类型
+	if err := mapping.LoadDir(docTypeDir); err != nil {
+		logger.Fatalln("Failed to load document type definitions: ", err)
+	}
+
+	// 用户自定义的座席类别映射
+	if seatCategoryFile := os.Getenv("SEAT_CATEGORY_FILE"); seatCategoryFile != "" {
+		if err := train.LoadSeatCategories(seatCategoryFile); err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 以完整的车站表替换内置的种子字典
+	if dictionaryFile := os.Getenv("STATION_DICTIONARY_FILE"); dictionaryFile != "" {
+		if err := station.LoadDictionary(dictionaryFile); err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 补充车站字典
+	if stationFile := os.Getenv("STATION_FILE"); stationFile != "" {
+		if err := station.LoadFile(stationFile); err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 用于推算抵达日期的本地时刻表
+	if scheduleFile := os.Getenv("TRAIN_SCHEDULE_FILE"); scheduleFile != "" {
+		schedule, err := train.LoadCSVSchedule(scheduleFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		train.SetScheduleSource(schedule)
+	}
+
 	if BaiduClientId == "" || BaiduClientSecret == "" {
 		logger.Fatalln("BAIDU_CLIENT_ID or BAIDU_CLIENT_SECRET is not set")
 	}
@@ -56,6 +182,37 @@ func main() {
 	}
 	logger.Info("Access Token: ", accessToken)
 
+	// 差旅补助政策，未配置时不计算补助
+	var allowances *travel.AllowancePolicies
+	if policyFile := os.Getenv("ALLOWANCE_POLICY_FILE"); policyFile != "" {
+		allowances, err = travel.LoadAllowancePolicies(policyFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 员工目录，用于关联乘车人与部门、成本中心
+	var directory *employee.Directory
+	if directoryFile := os.Getenv("EMPLOYEE_DIRECTORY_FILE"); directoryFile != "" {
+		directory, err = employee.Load(directoryFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 差旅合规规则
+	var rules *compliance.Rules
+	if rulesFile := os.Getenv("COMPLIANCE_RULES_FILE"); rulesFile != "" {
+		rules, err = compliance.LoadRules(rulesFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		// 时刻表等数据在此之前加载，仍无法提供的字段使规则永远不会触发
+		for _, field := range rules.UnavailableFields() {
+			logger.Warnf("Compliance rules reference field %q, which no document provides; rules using it will never match", field)
+		}
+	}
+
 	if docDir == "" {
 		logger.Fatalln("DOC_DIR is not set")
 	}
@@ -64,56 +221,202 @@ func main() {
 	if len(docPaths) == 0 || err != nil {
 		logger.Fatalln("No docs found in the directory")
 	}
+	// 处理记录数据库，未配置时不保存历史
+	var db *store.Store
+	var run *store.Run
+	if storeFile := os.Getenv("STORE_FILE"); storeFile != "" {
+		db, err = store.Open(storeFile)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+		defer db.Close()
+		run, err = db.StartRun(docDir)
+		if err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	// 重复报销检查，配置了处理记录数据库时同时检查历史运行
+	policy, err := dedup.ParsePolicy(os.Getenv("DUPLICATE_POLICY"))
+	if err != nil {
+		logger.Fatalln(err)
+	}
+	detector := &dedup.Detector{}
+
+	// 识别前按感知哈希合并相似图像，阈值小于 0 时不合并
+	grouper := &dedup.ImageGrouper{Threshold: dedup.DefaultImageThreshold}
+	if threshold := os.Getenv("IMAGE_HASH_THRESHOLD"); threshold != "" {
+		if grouper.Threshold, err = strconv.Atoi(threshold); err != nil {
+			logger.Fatalln("Invalid IMAGE_HASH_THRESHOLD: ", err)
+		}
+	}
+	// 默认只跳过内容相同的文件，设置后相似图像也跳过识别
+	if threshold := os.Getenv("IMAGE_SKIP_THRESHOLD"); threshold != "" {
+		if grouper.SkipThreshold, err = strconv.Atoi(threshold); err != nil {
+			logger.Fatalln("Invalid IMAGE_SKIP_THRESHOLD: ", err)
+		}
+	}
+
+	summary := &runreport.Report{Policy: policy}
+	if db != nil {
+		detector.Keys = db
+		detector.RunID = run.ID
+		summary.RunID = run.ID
+	}
+
 	docList := make([]doctype.Document, 0)
+	records := make([]*store.Record, 0, len(docPaths))
 	for _, docPath := range docPaths {
 		logger.Info("Processing doc: ", docPath)
+		sourceHash, err := utils.FileHash(docPath)
+		if err != nil {
+			logger.Error(err)
+			summary.Failed++
+			continue
+		}
+		record := &store.Record{SourcePath: docPath, SourceHash: sourceHash, Provider: provider}
+		records = append(records, record)
+
 		imageBytes, err := utils.ImageResize(docPath)
 		if err != nil {
 			logger.Error(err)
+			record.Error = err.Error()
+			summary.Failed++
+			continue
+		}
+
+		// PDF 不计算感知哈希，总是提交识别；相似但内容不同的图像默认同样提交识别，识别后提示复核
+		similarTo := ""
+		if strings.ToLower(filepath.Ext(docPath)) != ".pdf" {
+			hash, err := utils.DHash(imageBytes)
+			if err != nil {
+				logger.Error(docPath, ": ", err)
+			} else if representative, skip, similar := grouper.Add(docPath, sourceHash, hash); skip && similar {
+				record.Error = fmt.Sprintf("与 %s 图像相似，未提交识别", representative)
+				continue
+			} else if skip {
+				record.Error = fmt.Sprintf("与 %s 内容相同，未提交识别", representative)
+				continue
+			} else if similar {
+				similarTo = representative
+			}
 		}
+
 		response, err := utils.GetMultipleInvoice(imageBytes, accessToken)
 		if err != nil {
 			logger.Error(err)
+			record.Error = err.Error()
+			summary.Failed++
+			continue
 		}
+		record.RawResponse = response
 
 		//logger.Debug(string(response))
 
 		finDoc, err := proc.ProcessInvoice(response)
+		if err != nil {
+			logger.Error(docPath, ": ", err)
+			record.Error = err.Error()
+			summary.Failed++
+			continue
+		}
+		record.Document = finDoc
+		summary.Processed++
+
+		// 导出中链接原始文件，使用绝对路径使链接不依赖导出目录
+		if sourced, ok := finDoc.(doctype.Sourced); ok {
+			sourcePath, err := filepath.Abs(docPath)
+			if err != nil {
+				sourcePath = docPath
+			}
+			sourced.SetSource(sourcePath)
+
+			// PDF 没有可缩放的图像，只保留原始文件的链接
+			if *exportThumbnails && strings.ToLower(filepath.Ext(docPath)) != ".pdf" {
+				thumbnail, err := utils.Thumbnail(imageBytes, utils.DefaultThumbnailSize)
+				if err != nil {
+					logger.Warn(docPath, ": ", err)
+				} else {
+					sourced.SetThumbnail(thumbnail)
+				}
+			}
+		}
+
+		duplicate, err := detector.Check(finDoc, docPath, sourceHash)
 		if err != nil {
 			logger.Error(err)
 		}
+		// 票号不重复但图像相似时，可能是同一票据识别出了不同的票号，也可能只是版式相同
+		if marker, ok := finDoc.(doctype.DuplicateMarker); ok && duplicate == nil && similarTo != "" {
+			marker.MarkSimilar(fmt.Sprintf("与 %s 图像相似，请核对", similarTo))
+		}
+		if duplicate != nil {
+			record.Key = duplicate.Key
+			record.Duplicate = true
+			if policy == dedup.PolicySkip {
+				continue
+			}
+			if marker, ok := finDoc.(doctype.DuplicateMarker); ok {
+				marker.MarkDuplicate(duplicate.Note())
+			}
+		}
 		docList = append(docList, finDoc)
 	}
+	summary.Duplicates = detector.Duplicates
+	summary.Reprocessed = len(detector.Reprocessed)
+	summary.ImageGroups = grouper.Groups()
 
-	factory := &proc.DocumentFactory{}
-	collections := make(map[doctype.DocumentType]doctype.DocumentCollection)
+	// 关联同类文档，例如退票费凭证与原车票
+	proc.Reconcile(docList)
 
-	// 将文档分类到对应的集合中
-	for _, finDoc := range docList {
-		var docType doctype.DocumentType
-		var doc doctype.Document
+	// 关联员工信息，可选按部门排列导出记录
+	trips := &travel.Collection{Allowances: allowances}
+	if directory != nil {
+		employee.Enrich(directory, docList)
+		trips.GradeResolver = directory.Grade
+		if os.Getenv("EXPORT_GROUP_BY") == "department" {
+			employee.SortByDepartment(docList)
+		}
+	}
 
-		switch d := finDoc.(type) {
-		case *vat.Doc:
-			docType = doctype.TypeVatInvoice
-			doc = d
-		case *train.Doc:
-			docType = doctype.TypeTrainTicket
-			doc = d
+	// 合规检查依赖员工职级，需在关联员工之后进行；重复报销的票据已标记，不再检查
+	if rules != nil {
+		logger.Infof("Found %d compliance violations", rules.Check(doctype.ExcludeDuplicates(docList)))
+	}
+
+	// 文档在关联与检查之后保存，记录中包含补充的员工等信息
+	if db != nil {
+		if err := db.SaveRecords(run, records); err != nil {
+			logger.Error(err)
 		}
+	}
 
-		if collection, exists := collections[docType]; exists {
-			collection.Add(doc)
-		} else {
-			collection := factory.CreateCollection(docType)
-			collection.Add(doc)
-			collections[docType] = collection
+	// 将文档按注册表分组到对应的集合中
+	collections := proc.NewCollections()
+	collections.AddReport(travel.Output, trips)
+	collections.AddReport(tax.Output, &tax.Collection{})
+	if rules != nil {
+		collections.AddReport(compliance.Output, &compliance.Report{})
+	}
+	for _, finDoc := range docList {
+		if err := collections.Add(finDoc); err != nil {
+			logger.Error(err)
 		}
 	}
 
-	// 统一处理所有集合的保存
-	for _, collection := range collections {
-		if err := collection.SaveToFile(); err != nil {
+	// 统一处理所有集合的导出
+	for _, err := range collections.Export(exporter) {
+		logger.Error(err)
+	}
+	if err := exporter.Export(runreport.Output, summary.Sheets()); err != nil {
+		logger.Error(err)
+	}
+	if err := export.Finish(exporter); err != nil {
+		logger.Error(err)
+	}
+
+	if db != nil {
+		if err := db.FinishRun(run); err != nil {
 			logger.Error(err)
 		}
 	}
@@ -121,3 +424,62 @@ func main() {
 	logger.Info("处理完成，按'Enter'以继续...")
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 }
+
+// rebuildBundle 从已导出的 Excel 工作簿生成打印用的票据合集，不重新识别。
+// 未指定工作簿时读取导出目录中的所有工作簿，表名与行号取自工作簿中的实际位置
+func rebuildBundle(args []string, docTypeDir string) {
+	logger := config.GetLogger()
+
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	exportDir := flags.String("out", os.Getenv("EXPORT_DIR"), "directory of the exported workbooks, the printable PDF is written here")
+	exportBundle := flags.String("bundle", os.Getenv("EXPORT_BUNDLE"), "name of the printable PDF")
+	bundleGrid := flags.String("bundle-grid", os.Getenv("BUNDLE_GRID"), "documents per page of the printable PDF, columns x rows such as 2x2")
+	flags.Parse(args)
+
+	if *exportBundle == "" {
+		logger.Fatalln("The name of the printable PDF is not set, use -bundle or EXPORT_BUNDLE")
+	}
+	grid, err := export.ParseGrid(*bundleGrid)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	// 自定义票据类型的表头同样需要识别
+	if err := mapping.LoadDir(docTypeDir); err != nil {
+		logger.Fatalln("Failed to load document type definitions: ", err)
+	}
+	var known []doctype.Sheet
+	for _, r := range doctype.Registrations() {
+		known = append(known, r.NewCollection().Sheets()...)
+	}
+
+	workbooks := flags.Args()
+	if len(workbooks) == 0 {
+		dir := *exportDir
+		if dir == "" {
+			dir = "."
+		}
+		workbooks, err = filepath.Glob(filepath.Join(dir, "*.xlsx"))
+		if err != nil {
+			logger.Fatalln(err)
+		}
+	}
+
+	bundle := &export.BundleExporter{
+		Path:     filepath.Join(*exportDir, *exportBundle+".pdf"),
+		Grid:     grid,
+		FontFile: os.Getenv("BUNDLE_FONT_FILE"),
+	}
+	for _, workbook := range workbooks {
+		// Excel 打开文件时生成的临时文件
+		if strings.HasPrefix(filepath.Base(workbook), "~$") {
+			continue
+		}
+		if err := bundle.AddWorkbook(workbook, known); err != nil {
+			logger.Error(err)
+		}
+	}
+	if err := bundle.Finish(); err != nil {
+		logger.Fatalln(err)
+	}
+}
@@ -0,0 +1,44 @@
+package field
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// FirstWord 按顺序尝试多个字段名，返回第一个非空的识别结果。
+// 百度不同票据类型（以及同一类型的不同版式）对同一含义的字段命名并不统一，
+// 例如发票号码可能是 InvoiceNum 也可能是 invoice_number。
+func FirstWord(result gjson.Result, keys ...string) string {
+	for _, key := range keys {
+		if word := Word(result, key); word != "" {
+			return word
+		}
+	}
+	return ""
+}
+
+// Word 读取单个字段的识别结果，兼容 {"key":[{"word":..}]} 与 {"key":{"word":..}} 两种结构
+func Word(result gjson.Result, key string) string {
+	value := result.Get(key)
+	if value.IsArray() {
+		value = value.Get("0")
+	}
+	return strings.TrimSpace(value.Get("word").String())
+}
+
+// NormalizeDate 将"2024年01月02日"格式的日期统一为"2024.01.02"
+func NormalizeDate(s string) string {
+	s = strings.ReplaceAll(s, "年", ".")
+	s = strings.ReplaceAll(s, "月", ".")
+	s = strings.ReplaceAll(s, "日", "")
+	return strings.TrimSpace(s)
+}
+
+// NormalizeMoney 去除金额中的货币符号与单位
+func NormalizeMoney(s string) string {
+	for _, symbol := range []string{"￥", "¥", "元", ",", "，"} {
+		s = strings.ReplaceAll(s, symbol, "")
+	}
+	return strings.TrimSpace(s)
+}
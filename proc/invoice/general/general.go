@@ -0,0 +1,161 @@
+package general
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/proc/field"
+	"fmt"
+	"github.com/tidwall/gjson"
+)
+
+var logger = config.GetLogger()
+
+// output 导出文件名
+const output = "通用发票处理结果"
+
+// subTypeNames 各类小额通用发票在导出表中显示的名称
+var subTypeNames = map[doctype.DocumentType]string{
+	doctype.TypeQuotaInvoice:       "定额发票",
+	doctype.TypeRollNormalInvoice:  "卷式普通发票",
+	doctype.TypePrintedInvoice:     "通用机打发票",
+	doctype.TypePrintedElecInvoice: "机打电子发票",
+	doctype.TypeLimitInvoice:       "限额发票",
+}
+
+func init() {
+	// 各类小额通用发票共用同一个处理器，并汇总到同一张导出表中
+	processor := &Processor{}
+	for _, t := range []doctype.DocumentType{
+		doctype.TypeQuotaInvoice,
+		doctype.TypeRollNormalInvoice,
+		doctype.TypePrintedInvoice,
+		doctype.TypePrintedElecInvoice,
+		doctype.TypeLimitInvoice,
+	} {
+		doctype.Register(doctype.Registration{
+			Type:          t,
+			Name:          subTypeNames[t],
+			Processor:     processor,
+			Group:         "general_invoice",
+			NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+			Output:        output,
+		})
+	}
+}
+
+// Doc represents small-value general invoice data
+type Doc struct {
+	DocType    doctype.DocumentType
+	DocCode    string
+	DocNumber  string
+	Date       string
+	Amount     string
+	SellerName string
+	CheckCode  string
+	doctype.DuplicateMark
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	return fmt.Sprintf("DocType: %s, DocCode: %s, DocNumber: %s, Date: %s, Amount: %s, SellerName: %s, CheckCode: %s",
+		d.DocType, d.DocCode, d.DocNumber, d.Date, d.Amount, d.SellerName, d.CheckCode)
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return d.DocType
+}
+
+// SubTypeName 返回票据子类型的中文名称
+func (d *Doc) SubTypeName() string {
+	if name, ok := subTypeNames[d.DocType]; ok {
+		return name
+	}
+	return string(d.DocType)
+}
+
+// DocumentKey 以发票代码与发票号码标识一张发票
+func (d *Doc) DocumentKey() string {
+	if d.DocNumber == "" {
+		return ""
+	}
+	return d.DocCode + "-" + d.DocNumber
+}
+
+func (d *Doc) AmendData() {
+	d.Date = field.NormalizeDate(d.Date)
+	d.Amount = field.NormalizeMoney(d.Amount)
+}
+
+// Processor 处理定额、卷式、机打及限额等小额通用发票，
+// 这几类发票字段基本一致，只是百度返回的字段命名有所不同
+type Processor struct{}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{
+		DocType: doctype.DocumentType(gjson.GetBytes(data, "words_result.0.type").String()),
+	}
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	d.DocCode = field.FirstWord(wordsResult, "InvoiceCode", "invoice_code")
+	d.DocNumber = field.FirstWord(wordsResult, "InvoiceNum", "InvoiceNumber", "invoice_number")
+	d.Date = field.FirstWord(wordsResult, "InvoiceDate", "Date", "invoice_date")
+	d.Amount = field.FirstWord(wordsResult,
+		"AmountInFiguers", "AmountInFigures", "TotalTax", "TotalAmount",
+		"invoice_rate_in_figure", "invoice_rate")
+	d.SellerName = field.FirstWord(wordsResult, "SellerName", "seller_name")
+	d.CheckCode = field.FirstWord(wordsResult, "CheckCode", "check_code")
+	d.AmendData()
+
+	logger.Info("General invoice data: ", d)
+	return &d, nil
+}
+
+type Docs []Doc
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	*docs = append(*docs, *d)
+}
+
+// columns 导出列
+var columns = []doctype.Column{
+	{Key: "sub_type", Header: "票据类型"},
+	{Key: "doc_code", Header: "发票代码"},
+	{Key: "doc_number", Header: "发票号码", Role: doctype.RoleNumber},
+	{Key: "date", Header: "开票日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+	{Key: "amount", Header: "金额", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+	{Key: "seller_name", Header: "销售方"},
+	{Key: "check_code", Header: "校验码"},
+	{Key: "duplicate", Header: "重复", Warning: true},
+	{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+}
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, d := range *docs {
+		rows = append(rows, []interface{}{
+			d.SubTypeName(),
+			d.DocCode,
+			d.DocNumber,
+			d.Date,
+			d.Amount,
+			d.SellerName,
+			d.CheckCode,
+			d.DuplicateNote(),
+			d.SourcePath(),
+			d.Thumbnail(),
+		})
+	}
+
+	return []doctype.Sheet{{Name: "通用发票", Columns: columns, Rows: rows, Keys: []string{"doc_code", "doc_number"}}}
+}
@@ -0,0 +1,89 @@
+package general
+
+import (
+	"FinDocOCR/doctype"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessQuotaInvoice(t *testing.T) {
+	// 定额发票的字段为小写下划线命名，金额只有 invoice_rate_in_figure
+	response := `{
+		"log_id": 1784512093456789012,
+		"words_result_num": 1,
+		"words_result": [{
+			"top": 12,
+			"left": 20,
+			"width": 820,
+			"height": 460,
+			"probability": 0.9987,
+			"type": "quota_invoice",
+			"result": {
+				"invoice_code": [{"word": "144031909110"}],
+				"invoice_number": [{"word": "07654321"}],
+				"invoice_rate": [{"word": "伍元"}],
+				"invoice_rate_in_figure": [{"word": "5.00"}],
+				"invoice_rate_in_words": [{"word": "伍元"}],
+				"province": [{"word": "广东省"}],
+				"city": [{"word": "深圳市"}],
+				"flag_receipt": [{"word": "否"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, doctype.DocumentType(doctype.TypeQuotaInvoice), d.DocType)
+	assert.Equal(t, "定额发票", d.SubTypeName())
+	assert.Equal(t, "144031909110", d.DocCode)
+	assert.Equal(t, "07654321", d.DocNumber)
+	assert.Equal(t, "5.00", d.Amount)
+	assert.Equal(t, "144031909110-07654321", d.DocumentKey())
+}
+
+func TestProcessRollInvoice(t *testing.T) {
+	// 卷式发票沿用增值税发票的驼峰命名，日期与金额需要规范化
+	response := `{
+		"log_id": 1784512093456789013,
+		"words_result_num": 1,
+		"words_result": [{
+			"probability": 0.9912,
+			"type": "roll_normal_invoice",
+			"result": {
+				"InvoiceCode": [{"word": "044031900104"}],
+				"InvoiceNum": [{"word": "12345678"}],
+				"InvoiceDate": [{"word": "2024年03月15日"}],
+				"AmountInFiguers": [{"word": "￥36.50"}],
+				"SellerName": [{"word": "深圳市某某餐饮有限公司"}],
+				"CheckCode": [{"word": "12345678901234567890"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "卷式普通发票", d.SubTypeName())
+	assert.Equal(t, "2024.03.15", d.Date)
+	assert.Equal(t, "36.50", d.Amount)
+	assert.Equal(t, "深圳市某某餐饮有限公司", d.SellerName)
+
+	docs := &Docs{}
+	docs.Add(doc)
+	sheets := docs.Sheets()
+	require.Len(t, sheets, 1)
+	record := sheets[0].Record(0)
+	assert.Equal(t, "卷式普通发票", record["sub_type"])
+	assert.Equal(t, "12345678", record["doc_number"])
+	assert.Equal(t, "36.50", record["amount"])
+}
+
+func TestProcessErrorResponse(t *testing.T) {
+	_, err := (&Processor{}).Process([]byte(`{"error_code": 17, "error_msg": "Open api daily request limit reached"}`))
+	assert.Error(t, err)
+}
@@ -5,13 +5,24 @@ import (
 	"FinDocOCR/doctype"
 	"fmt"
 	"github.com/tidwall/gjson"
-	"github.com/xuri/excelize/v2"
-	"log"
 	"strings"
 )
 
 var logger = config.GetLogger()
 
+// output 导出文件名
+const output = "增值税发票处理结果"
+
+func init() {
+	doctype.Register(doctype.Registration{
+		Type:          doctype.TypeVatInvoice,
+		Name:          "增值税发票",
+		Processor:     &Processor{},
+		NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+		Output:        output,
+	})
+}
+
 // Doc represents VAT Doc data
 type Doc struct {
 	DocCode          string
@@ -21,6 +32,8 @@ type Doc struct {
 	TotalAmount      string
 	CommodityTaxRate string
 	TotalTax         string
+	doctype.DuplicateMark
+	doctype.Source
 }
 
 func (d *Doc) String() string {
@@ -28,6 +41,18 @@ func (d *Doc) String() string {
 		d.DocCode, d.DocNumber, d.Date, d.CommodityName, d.TotalAmount, d.CommodityTaxRate, d.TotalTax)
 }
 
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return doctype.TypeVatInvoice
+}
+
+// DocumentKey 以发票代码与发票号码标识一张发票，全电发票没有发票代码
+func (d *Doc) DocumentKey() string {
+	if d.DocNumber == "" {
+		return ""
+	}
+	return d.DocCode + "-" + d.DocNumber
+}
+
 func (d *Doc) AmendData() {
 	// 处理日期
 	d.Date = strings.ReplaceAll(d.Date, "年", ".")
@@ -72,39 +97,24 @@ func (docs *Docs) Add(doc doctype.Document) {
 	*docs = append(*docs, *d)
 }
 
-func (docs *Docs) SaveToFile() error {
-	// 初始化 Excel 文件
-	f := excelize.NewFile()
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	// 设置常量
-	const (
-		filename  = "增值税发票处理结果.xlsx"
-		sheetName = "Sheet1" // Excel 默认的工作表名称
-	)
-
-	// 创建流式写入器，使用正确的 sheet 名称
-	sw, err := f.NewStreamWriter(sheetName)
-	if err != nil {
-		return fmt.Errorf("failed to create stream writer: %w", err)
-	}
-
-	// 写入表头
-	headers := []interface{}{
-		"发票代码", "发票号码", "开票日期",
-		"货物名称", "金额", "税率", "税额",
-	}
-	if err := sw.SetRow("A1", headers); err != nil {
-		return fmt.Errorf("failed to write headers: %w", err)
-	}
+// columns 导出列
+var columns = []doctype.Column{
+	{Key: "doc_code", Header: "发票代码"},
+	{Key: "doc_number", Header: "发票号码", Role: doctype.RoleNumber},
+	{Key: "date", Header: "开票日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+	{Key: "commodity_name", Header: "货物名称"},
+	{Key: "total_amount", Header: "金额", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+	{Key: "tax_rate", Header: "税率", Role: doctype.RoleTaxRate},
+	{Key: "total_tax", Header: "税额", Type: doctype.ColumnMoney, Role: doctype.RoleTax},
+	{Key: "duplicate", Header: "重复", Warning: true},
+	{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+}
 
-	// 写入数据行
-	for i, d := range *docs {
-		rowData := []interface{}{
+func (docs *Docs) Sheets() []doctype.Sheet {
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, d := range *docs {
+		rows = append(rows, []interface{}{
 			d.DocCode,
 			d.DocNumber,
 			d.Date,
@@ -112,22 +122,11 @@ func (docs *Docs) SaveToFile() error {
 			d.TotalAmount,
 			d.CommodityTaxRate,
 			d.TotalTax,
-		}
-
-		if err := sw.SetRow(fmt.Sprintf("A%d", i+2), rowData); err != nil {
-			return fmt.Errorf("failed to write row %d: %w", i+2, err)
-		}
-	}
-
-	// 刷新流式写入器
-	if err := sw.Flush(); err != nil {
-		return fmt.Errorf("failed to flush stream writer: %w", err)
-	}
-
-	// 保存文件
-	if err := f.SaveAs(filename); err != nil {
-		return fmt.Errorf("failed to save file: %w", err)
+			d.DuplicateNote(),
+			d.SourcePath(),
+			d.Thumbnail(),
+		})
 	}
 
-	return nil
+	return []doctype.Sheet{{Name: "增值税发票", Columns: columns, Rows: rows, Keys: []string{"doc_code", "doc_number"}}}
 }
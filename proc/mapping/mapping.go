@@ -0,0 +1,427 @@
+package mapping
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/proc/field"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var logger = config.GetLogger()
+
+// FieldDefinition 描述一个字段：从哪些识别字段取值、导出的列名以及规范化步骤
+type FieldDefinition struct {
+	// Sources 依次尝试的识别字段名，包含"."时按 gjson 路径解析（相对于识别结果）
+	Sources []string `yaml:"sources" json:"sources"`
+	Column  string   `yaml:"column" json:"column"`
+	// Key JSON 等格式中使用的字段名，为空时使用列名
+	Key string `yaml:"key" json:"key"`
+	// Normalize 规范化步骤，按顺序执行，如 trim、date、money、strip_prefix:*
+	Normalize []string `yaml:"normalize" json:"normalize"`
+	// Role 列的含义，如 amount、date、person、number
+	Role doctype.ColumnRole `yaml:"role" json:"role"`
+}
+
+// Definition 以配置文件声明的文档类型
+type Definition struct {
+	Type  doctype.DocumentType `yaml:"type" json:"type"`
+	Name  string               `yaml:"name" json:"name"`
+	Group string               `yaml:"group" json:"group"`
+	// Output 导出文件名（不含扩展名）
+	Output string            `yaml:"output" json:"output"`
+	Fields []FieldDefinition `yaml:"fields" json:"fields"`
+	// ExportOrder 导出列顺序，为空时按 Fields 的顺序导出
+	ExportOrder []string `yaml:"export_order" json:"export_order"`
+	// Unique 唯一标识一张票据的列，用于判断重复报销，为空时不判断
+	Unique []string `yaml:"unique" json:"unique"`
+}
+
+// Validate 检查定义是否完整，并补齐默认值
+func (def *Definition) Validate() error {
+	if def.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if len(def.Fields) == 0 {
+		return fmt.Errorf("%s: at least one field is required", def.Type)
+	}
+	if def.Name == "" {
+		def.Name = string(def.Type)
+	}
+	// 名称同时用作导出表名
+	if err := doctype.ValidateSheetName(def.Name); err != nil {
+		return fmt.Errorf("%s: name: %w", def.Type, err)
+	}
+	if def.Output == "" {
+		def.Output = def.Name + "处理结果"
+	}
+
+	columns := make(map[string]bool)
+	for _, f := range def.Fields {
+		if f.Column == "" || len(f.Sources) == 0 {
+			return fmt.Errorf("%s: field requires column and sources", def.Type)
+		}
+		if columns[f.Column] {
+			return fmt.Errorf("%s: duplicate column %s", def.Type, f.Column)
+		}
+		columns[f.Column] = true
+		for _, step := range f.Normalize {
+			if _, err := normalizer(step); err != nil {
+				return fmt.Errorf("%s: column %s: %w", def.Type, f.Column, err)
+			}
+		}
+		switch f.Role {
+		case "", doctype.RoleAmount, doctype.RoleTax, doctype.RoleTaxRate, doctype.RoleDate, doctype.RolePerson, doctype.RoleNumber:
+		default:
+			return fmt.Errorf("%s: column %s: unknown role %q", def.Type, f.Column, f.Role)
+		}
+	}
+	for _, column := range def.ExportOrder {
+		if !columns[column] {
+			return fmt.Errorf("%s: export_order references unknown column %s", def.Type, column)
+		}
+	}
+	for _, column := range def.Unique {
+		if !columns[column] {
+			return fmt.Errorf("%s: unique references unknown column %s", def.Type, column)
+		}
+	}
+	return nil
+}
+
+// Columns 返回导出列名
+func (def *Definition) Columns() []string {
+	if len(def.ExportOrder) > 0 {
+		return def.ExportOrder
+	}
+	columns := make([]string, 0, len(def.Fields))
+	for _, f := range def.Fields {
+		columns = append(columns, f.Column)
+	}
+	return columns
+}
+
+// role 返回列在汇总表中的含义
+func (def *Definition) role(column string) doctype.ColumnRole {
+	for _, f := range def.Fields {
+		if f.Column == column {
+			return f.Role
+		}
+	}
+	return ""
+}
+
+// personColumn 返回含义为人员的列，没有时返回空字符串
+func (def *Definition) personColumn() string {
+	for _, f := range def.Fields {
+		if f.Role == doctype.RolePerson {
+			return f.Column
+		}
+	}
+	return ""
+}
+
+// key 返回列对应的字段名
+func (def *Definition) key(column string) string {
+	for _, f := range def.Fields {
+		if f.Column == column && f.Key != "" {
+			return f.Key
+		}
+	}
+	return column
+}
+
+// normalizer 解析规范化步骤，步骤参数以":"分隔
+func normalizer(step string) (func(string) string, error) {
+	name, arg, _ := strings.Cut(step, ":")
+	switch name {
+	case "trim":
+		return strings.TrimSpace, nil
+	case "date":
+		return field.NormalizeDate, nil
+	case "money":
+		return field.NormalizeMoney, nil
+	case "strip_prefix":
+		// 去除最后一个分隔符及其之前的内容，如"*餐饮服务*餐费"取"餐费"，没有分隔符时保持不变
+		if arg == "" {
+			return nil, fmt.Errorf("strip_prefix requires an argument")
+		}
+		return func(s string) string {
+			idx := strings.LastIndex(s, arg)
+			if idx < 0 {
+				return s
+			}
+			return s[idx+len(arg):]
+		}, nil
+	case "remove":
+		if arg == "" {
+			return nil, fmt.Errorf("remove requires an argument")
+		}
+		return func(s string) string {
+			return strings.ReplaceAll(s, arg, "")
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown normalize step %q", step)
+	}
+}
+
+// Doc represents a document of a declaratively defined type
+type Doc struct {
+	definition *Definition
+	DocType    doctype.DocumentType
+	Values     map[string]string
+	// 人员列关联的员工信息，定义中没有人员列时不导出
+	employee.Assignment
+	doctype.DuplicateMark
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	pairs := make([]string, 0, len(d.Values))
+	for _, column := range d.definition.Columns() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", column, d.Values[column]))
+	}
+	return fmt.Sprintf("DocType: %s, %s", d.DocType, strings.Join(pairs, ", "))
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return d.DocType
+}
+
+// PersonName 返回人员列的值，用于匹配员工
+func (d *Doc) PersonName() string {
+	if column := d.definition.personColumn(); column != "" {
+		return d.Values[column]
+	}
+	return ""
+}
+
+// DocumentKey 由定义中 unique 列的值组成，任一列为空时无法判断
+func (d *Doc) DocumentKey() string {
+	if len(d.definition.Unique) == 0 {
+		return ""
+	}
+	values := make([]string, 0, len(d.definition.Unique))
+	for _, column := range d.definition.Unique {
+		if d.Values[column] == "" {
+			return ""
+		}
+		values = append(values, d.Values[column])
+	}
+	return strings.Join(values, "|")
+}
+
+func (d *Doc) AmendData() {
+	for _, f := range d.definition.Fields {
+		value := d.Values[f.Column]
+		for _, step := range f.Normalize {
+			// 定义在加载时已校验，此处不会出错
+			normalize, _ := normalizer(step)
+			value = normalize(value)
+		}
+		d.Values[f.Column] = value
+	}
+}
+
+// Processor 按定义从识别结果中提取字段
+type Processor struct {
+	definition *Definition
+}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{
+		definition: p.definition,
+		DocType:    p.definition.Type,
+		Values:     make(map[string]string),
+	}
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	for _, f := range p.definition.Fields {
+		d.Values[f.Column] = lookup(wordsResult, f.Sources)
+	}
+	d.AmendData()
+
+	logger.Infof("%s data: %s", p.definition.Name, d.String())
+	return &d, nil
+}
+
+// lookup 依次尝试各个来源，返回第一个非空值
+func lookup(wordsResult gjson.Result, sources []string) string {
+	for _, source := range sources {
+		var value string
+		if strings.Contains(source, ".") {
+			value = strings.TrimSpace(wordsResult.Get(source).String())
+		} else {
+			value = field.Word(wordsResult, source)
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+type Docs struct {
+	definition *Definition
+	docs       []Doc
+}
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	docs.docs = append(docs.docs, *d)
+}
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	columns := docs.definition.Columns()
+	sheetColumns := make([]doctype.Column, 0, len(columns))
+	for _, column := range columns {
+		sheetColumns = append(sheetColumns, doctype.Column{
+			Key:    docs.definition.key(column),
+			Header: column,
+			Role:   docs.definition.role(column),
+		})
+	}
+	// 声明了唯一列的类型才会判断重复
+	unique := len(docs.definition.Unique) > 0
+	// 声明了人员列的类型才会关联员工
+	person := docs.definition.personColumn() != ""
+	if person {
+		sheetColumns = append(sheetColumns,
+			doctype.Column{Key: "employee_id", Header: "工号"},
+			doctype.Column{Key: "department", Header: "部门"},
+			doctype.Column{Key: "cost_center", Header: "成本中心"},
+			doctype.Column{Key: "match_status", Header: "员工匹配", Warning: true},
+		)
+	}
+	if unique {
+		sheetColumns = append(sheetColumns, doctype.Column{Key: "duplicate", Header: "重复", Warning: true})
+	}
+	sheetColumns = append(sheetColumns,
+		doctype.Column{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+		doctype.Column{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+	)
+
+	rows := make([][]interface{}, 0, len(docs.docs))
+	for _, d := range docs.docs {
+		row := make([]interface{}, 0, len(sheetColumns))
+		for _, column := range columns {
+			row = append(row, d.Values[column])
+		}
+		if person {
+			row = append(row, d.EmployeeID, d.Department, d.CostCenter, d.MatchStatus)
+		}
+		if unique {
+			row = append(row, d.DuplicateNote())
+		}
+		row = append(row, d.SourcePath(), d.Thumbnail())
+		rows = append(rows, row)
+	}
+
+	keys := make([]string, 0, len(docs.definition.Unique))
+	for _, column := range docs.definition.Unique {
+		keys = append(keys, docs.definition.key(column))
+	}
+
+	return []doctype.Sheet{{Name: docs.definition.Name, Columns: sheetColumns, Rows: rows, Keys: keys}}
+}
+
+// Parse 解析单个定义文件的内容，YAML 与 JSON 格式均可
+func Parse(content []byte) (*Definition, error) {
+	var def Definition
+	if err := yaml.Unmarshal(content, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse definition: %w", err)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// defined 已从配置文件注册的类型，同一类型只能定义一次
+var defined = make(map[doctype.DocumentType]bool)
+
+// Register 将定义注册到文档类型注册表中。定义的类型为内置类型时替换内置的处理器与导出格式，
+// 但兜底类型（others）不能被替换。
+// 分组对应一个集合，不能与其他类型共用，否则集合中会混入其他处理器生成的文档。
+func Register(def *Definition) error {
+	if defined[def.Type] {
+		return fmt.Errorf("document type %s is defined more than once", def.Type)
+	}
+	group := def.Group
+	if group == "" {
+		group = string(def.Type)
+	}
+	for _, r := range doctype.Registrations() {
+		if r.Group == group && r.Type != def.Type {
+			return fmt.Errorf("group %s is already used by document type %s", group, r.Type)
+		}
+	}
+
+	registration := doctype.Registration{
+		Type:          def.Type,
+		Name:          def.Name,
+		Processor:     &Processor{definition: def},
+		Group:         group,
+		NewCollection: func() doctype.DocumentCollection { return &Docs{definition: def} },
+		Output:        def.Output,
+	}
+	if _, exists := doctype.Lookup(def.Type); exists {
+		if err := doctype.Override(registration); err != nil {
+			return err
+		}
+		logger.Warnf("Document type %s is overridden by definition %s", def.Type, def.Name)
+	} else {
+		doctype.Register(registration)
+	}
+	defined[def.Type] = true
+	return nil
+}
+
+// LoadDir 加载目录下所有 .yaml、.yml 与 .json 定义文件并注册，目录不存在时直接返回
+func LoadDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read definition dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		def, err := Parse(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := Register(def); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		logger.Infof("Loaded document type %s from %s", def.Type, path)
+	}
+	return nil
+}
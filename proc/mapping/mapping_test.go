@@ -0,0 +1,141 @@
+package mapping
+
+import (
+	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const taxiDefinition = `
+type: taxi_receipt_test
+name: 出租车票
+fields:
+  - column: 发票号码
+    key: invoice_number
+    sources: [InvoiceNum, invoice_number]
+  - column: 日期
+    sources: [Date]
+    normalize: [date]
+  - column: 金额
+    sources: [Fare, TotalFare]
+    normalize: [money]
+  - column: 项目
+    sources: [Commodity]
+    normalize: ["strip_prefix:*"]
+export_order: [日期, 发票号码, 金额, 项目]
+`
+
+const taxiResponse = `{
+	"words_result_num": 1,
+	"words_result": [{
+		"type": "taxi_receipt",
+		"result": {
+			"invoice_number": [{"word": "12345678"}],
+			"Date": [{"word": "2024年03月05日"}],
+			"TotalFare": [{"word": "￥35.00"}],
+			"Commodity": [{"word": "*运输服务*客运服务费"}]
+		}
+	}]
+}`
+
+func TestParseAndProcess(t *testing.T) {
+	def, err := Parse([]byte(taxiDefinition))
+	require.NoError(t, err)
+	assert.Equal(t, "出租车票处理结果", def.Output)
+	assert.Equal(t, []string{"日期", "发票号码", "金额", "项目"}, def.Columns())
+
+	doc, err := (&Processor{definition: def}).Process([]byte(taxiResponse))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "12345678", d.Values["发票号码"])
+	assert.Equal(t, "2024.03.05", d.Values["日期"])
+	assert.Equal(t, "35.00", d.Values["金额"])
+	assert.Equal(t, "客运服务费", d.Values["项目"])
+}
+
+func TestStripPrefix(t *testing.T) {
+	strip, err := normalizer("strip_prefix:*")
+	require.NoError(t, err)
+	assert.Equal(t, "餐费", strip("*餐饮服务*餐费"))
+	assert.Equal(t, "餐费", strip("餐费"))
+
+	// 多字符分隔符缺失时保持原值，不截断前面的字符
+	strip, err = normalizer("strip_prefix:——")
+	require.NoError(t, err)
+	assert.Equal(t, "住宿费", strip("酒店——住宿费"))
+	assert.Equal(t, "住宿服务费", strip("住宿服务费"))
+}
+
+func TestParseInvalidDefinition(t *testing.T) {
+	// 未知的规范化步骤
+	_, err := Parse([]byte(`{"type": "x", "fields": [{"column": "a", "sources": ["a"], "normalize": ["upper"]}]}`))
+	assert.Error(t, err)
+
+	// 导出顺序引用了不存在的列
+	_, err = Parse([]byte(`{"type": "x", "fields": [{"column": "a", "sources": ["a"]}], "export_order": ["b"]}`))
+	assert.Error(t, err)
+
+	// 名称用作导出表名，不能包含 Excel 不允许的字符
+	_, err = Parse([]byte(`{"type": "x", "name": "发票[电子]", "fields": [{"column": "a", "sources": ["a"]}]}`))
+	assert.Error(t, err)
+}
+
+func TestRegisterOverridesBuiltinType(t *testing.T) {
+	// 模拟内置类型：两个类型共用 builtin_group 分组
+	builtin, err := Parse([]byte(`{"type": "builtin_a", "fields": [{"column": "a", "sources": ["a"]}]}`))
+	require.NoError(t, err)
+	for _, docType := range []doctype.DocumentType{"builtin_a", "builtin_b"} {
+		doctype.Register(doctype.Registration{
+			Type:          docType,
+			Processor:     &Processor{definition: builtin},
+			Group:         "builtin_group",
+			NewCollection: func() doctype.DocumentCollection { return &Docs{definition: builtin} },
+		})
+	}
+
+	// 加入其他类型的分组会使集合中混入不同处理器的文档
+	def, err := Parse([]byte(`{"type": "custom_c", "group": "builtin_group", "fields": [{"column": "c", "sources": ["c"]}]}`))
+	require.NoError(t, err)
+	assert.Error(t, Register(def))
+
+	// 覆盖内置类型时使用独立的分组
+	def, err = Parse([]byte(`{"type": "builtin_a", "name": "覆盖", "fields": [{"column": "x", "sources": ["x"]}]}`))
+	require.NoError(t, err)
+	require.NoError(t, Register(def))
+
+	registration, ok := doctype.Lookup("builtin_a")
+	require.True(t, ok)
+	assert.Equal(t, "覆盖", registration.Name)
+	assert.Equal(t, "builtin_a", registration.Group)
+	assert.Equal(t, def, registration.Processor.(*Processor).definition)
+
+	// 同一类型不能重复定义
+	assert.Error(t, Register(def))
+}
+
+func TestPersonColumnMatchesEmployee(t *testing.T) {
+	def, err := Parse([]byte(`{"type": "hotel_test", "name": "住宿费", "fields": [
+		{"column": "入住人", "key": "guest", "sources": ["guest"], "role": "person"},
+		{"column": "金额", "sources": ["amount"], "role": "amount"}]}`))
+	require.NoError(t, err)
+	doc, err := (&Processor{definition: def}).Process([]byte(`{"words_result_num": 1, "words_result": [{"type": "hotel_test",
+		"result": {"guest": [{"word": "赵六"}], "amount": [{"word": "450.00"}]}}]}`))
+	require.NoError(t, err)
+
+	directory := employee.NewDirectory([]*employee.Employee{{Name: "赵六", ID: "E006", Department: "研发部"}})
+	employee.Enrich(directory, []doctype.Document{doc})
+
+	docs := &Docs{definition: def}
+	docs.Add(doc)
+	record := docs.Sheets()[0].Record(0)
+	assert.Equal(t, "E006", record["employee_id"])
+	assert.Equal(t, "研发部", record["department"])
+
+	// 没有人员列的类型不导出员工信息
+	docs = &Docs{definition: &Definition{Type: "x", Fields: []FieldDefinition{{Column: "a", Sources: []string{"a"}}}}}
+	assert.NotContains(t, docs.Sheets()[0].Headers(), "工号")
+}
@@ -0,0 +1,172 @@
+package other
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"strings"
+)
+
+var logger = config.GetLogger()
+
+// output 导出文件名
+const output = "其他票据处理结果"
+
+func init() {
+	doctype.Register(doctype.Registration{
+		Type:          doctype.TypeOthers,
+		Name:          "其他票据",
+		Processor:     &Processor{},
+		NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+		Output:        output,
+		Fallback:      true,
+	})
+}
+
+// Field 识别结果中的一个键值对
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Doc represents a document whose type has no dedicated processor.
+// 保留识别结果中的全部字段，保证识别出的内容不会丢失
+type Doc struct {
+	DocType doctype.DocumentType
+	Fields  []Field
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	pairs := make([]string, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", f.Key, f.Value))
+	}
+	return fmt.Sprintf("DocType: %s, %s", d.DocType, strings.Join(pairs, ", "))
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return d.DocType
+}
+
+func (d *Doc) AmendData() {
+	for i := range d.Fields {
+		d.Fields[i].Value = strings.TrimSpace(d.Fields[i].Value)
+	}
+}
+
+// Get 返回指定字段的值
+func (d *Doc) Get(key string) string {
+	for _, f := range d.Fields {
+		if f.Key == key {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Processor 通用兜底处理器，用于没有专门处理器的票据类型
+type Processor struct{}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{
+		DocType: doctype.DocumentType(gjson.GetBytes(data, "words_result.0.type").String()),
+		Fields:  make([]Field, 0),
+	}
+	if d.DocType == "" {
+		d.DocType = doctype.TypeOthers
+	}
+
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	// 按返回顺序保留全部字段
+	wordsResult.ForEach(func(key, value gjson.Result) bool {
+		d.Fields = append(d.Fields, Field{Key: key.String(), Value: joinWords(value)})
+		return true
+	})
+	d.AmendData()
+
+	logger.Info("Other document data: ", d)
+	return &d, nil
+}
+
+// joinWords 将字段的识别结果拼接为字符串，一个字段可能包含多行文字
+func joinWords(value gjson.Result) string {
+	switch {
+	case value.IsArray():
+		words := make([]string, 0)
+		for _, item := range value.Array() {
+			if word := joinWords(item); word != "" {
+				words = append(words, word)
+			}
+		}
+		return strings.Join(words, "; ")
+	case value.IsObject():
+		if word := value.Get("word"); word.Exists() {
+			return word.String()
+		}
+		return value.Raw
+	default:
+		return value.String()
+	}
+}
+
+type Docs []Doc
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	*docs = append(*docs, *d)
+}
+
+// columns 汇总所有文档出现过的字段，按首次出现的顺序排列
+func (docs *Docs) columns() []string {
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, d := range *docs {
+		for _, f := range d.Fields {
+			if !seen[f.Key] {
+				seen[f.Key] = true
+				columns = append(columns, f.Key)
+			}
+		}
+	}
+	return columns
+}
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	// 表头为票据类型、所有出现过的字段与原始文件
+	keys := docs.columns()
+	columns := make([]doctype.Column, 0, len(keys)+3)
+	columns = append(columns, doctype.Column{Key: "doc_type", Header: "票据类型"})
+	for _, key := range keys {
+		columns = append(columns, doctype.Column{Key: key, Header: key})
+	}
+	columns = append(columns,
+		doctype.Column{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+		doctype.Column{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+	)
+
+	// 文档中不存在的字段留空
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, d := range *docs {
+		row := make([]interface{}, 0, len(keys)+3)
+		row = append(row, string(d.DocType))
+		for _, key := range keys {
+			row = append(row, d.Get(key))
+		}
+		row = append(row, d.SourcePath(), d.Thumbnail())
+		rows = append(rows, row)
+	}
+
+	return []doctype.Sheet{{Name: "其他票据", Columns: columns, Rows: rows}}
+}
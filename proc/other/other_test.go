@@ -0,0 +1,79 @@
+package other
+
+import (
+	"FinDocOCR/doctype"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownTypeFallsBackToOther(t *testing.T) {
+	// 没有专门处理器的类型（如医疗发票）由兜底处理器处理，保留全部字段
+	response := `{
+		"log_id": 1784512093456789201,
+		"words_result_num": 1,
+		"words_result": [{
+			"probability": 0.9731,
+			"type": "medical_invoice",
+			"result": {
+				"InvoiceNum": [{"word": " 0123456789 "}],
+				"HospitalName": [{"word": "深圳市人民医院"}],
+				"TotalAmount": [{"word": "286.50"}],
+				"Items": [{"word": "西药费"}, {"word": "检查费"}]
+			}
+		}]
+	}`
+
+	registration, err := doctype.Resolve("medical_invoice")
+	require.NoError(t, err)
+	assert.Equal(t, doctype.DocumentType(doctype.TypeOthers), registration.Type)
+
+	doc, err := registration.Processor.Process([]byte(response))
+	require.NoError(t, err)
+	d := doc.(*Doc)
+	assert.Equal(t, doctype.DocumentType("medical_invoice"), d.DocumentType())
+	assert.Equal(t, "0123456789", d.Get("InvoiceNum"))
+	assert.Equal(t, "西药费; 检查费", d.Get("Items"))
+
+	// 另一张未知票据的字段不同，汇总表包含两者的全部字段，缺失的字段留空
+	other, err := registration.Processor.Process([]byte(`{
+		"words_result_num": 1,
+		"words_result": [{
+			"type": "parking_invoice",
+			"result": {
+				"InvoiceNum": [{"word": "88001234"}],
+				"ParkingTime": [{"word": "2小时"}]
+			}
+		}]
+	}`))
+	require.NoError(t, err)
+
+	collection := registration.NewCollection()
+	collection.Add(doc)
+	collection.Add(other)
+	sheets := collection.Sheets()
+	require.Len(t, sheets, 1)
+	assert.Equal(t, "其他票据", sheets[0].Name)
+	assert.Equal(t, []string{"票据类型", "InvoiceNum", "HospitalName", "TotalAmount", "Items", "ParkingTime", "原始文件", "缩略图"},
+		sheets[0].Headers())
+
+	first := sheets[0].Record(0)
+	assert.Equal(t, "medical_invoice", first["doc_type"])
+	assert.Equal(t, "286.50", first["TotalAmount"])
+	assert.Equal(t, "", first["ParkingTime"])
+
+	second := sheets[0].Record(1)
+	assert.Equal(t, "parking_invoice", second["doc_type"])
+	assert.Equal(t, "88001234", second["InvoiceNum"])
+	assert.Equal(t, "", second["HospitalName"])
+}
+
+func TestProcessWithoutType(t *testing.T) {
+	doc, err := (&Processor{}).Process([]byte(`{"words_result": [{"result": {"Title": [{"word": "收据"}]}}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, doctype.DocumentType(doctype.TypeOthers), doc.DocumentType())
+
+	_, err = (&Processor{}).Process([]byte(`{"error_code": 282000, "error_msg": "internal error"}`))
+	assert.Error(t, err)
+}
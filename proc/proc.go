@@ -3,74 +3,136 @@ package proc
 import (
 	"FinDocOCR/config"
 	"FinDocOCR/doctype"
-	"FinDocOCR/proc/invoice/vat"
-	"FinDocOCR/proc/ticket/train"
+	"FinDocOCR/export"
 	"fmt"
 	"github.com/tidwall/gjson"
 )
 
 var logger = config.GetLogger()
 
-// FinDocProcessor 票据处理器接口
-type FinDocProcessor interface {
-	Process(data []byte) (doctype.Document, error)
-}
+// ProcessInvoice 根据识别结果中的票据类型，从注册表中选择处理器解析文档。
+// 未注册的类型交给兜底处理器，保留全部识别字段。
+func ProcessInvoice(data []byte) (doctype.Document, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, fmt.Errorf("invalid json data")
+	}
 
-type ProcessorFactory struct {
-	processors map[string]FinDocProcessor
-}
+	if gjson.GetBytes(data, "words_result_num").Int() != 1 {
+		return nil, fmt.Errorf("该程序只支持单张票据的识别")
+	}
 
-func NewProcessorFactory() *ProcessorFactory {
-	factory := &ProcessorFactory{
-		processors: make(map[string]FinDocProcessor),
+	resultType := doctype.DocumentType(gjson.GetBytes(data, "words_result.0.type").String())
+	logger.Info("resultType: ", resultType)
+
+	registration, err := doctype.Resolve(resultType)
+	if err != nil {
+		return nil, err
+	}
+	if registration.Type != resultType {
+		logger.Warnf("unsupported invoice type: %s, fallback to %s processor", resultType, registration.Type)
 	}
 
-	factory.processors[doctype.TypeVatInvoice] = &vat.Processor{}
-	factory.processors[doctype.TypeTrainTicket] = &train.Processor{}
-	// TODO:注册其他处理器...
-	return factory
+	return registration.Processor.Process(data)
 }
 
-func (f *ProcessorFactory) GetProcessor(invoiceType string) (FinDocProcessor, error) {
-	processor, exists := f.processors[invoiceType]
-	if !exists {
-		return nil, fmt.Errorf("unsupported invoice type: %s", invoiceType)
+// Reconcile 按注册表分组，对设置了 Reconcile 的类型调用关联处理
+func Reconcile(docs []doctype.Document) {
+	groups := make(map[string][]doctype.Document)
+	for _, doc := range docs {
+		registration, err := doctype.Resolve(doc.DocumentType())
+		if err != nil || registration.Reconcile == nil {
+			continue
+		}
+		groups[registration.Group] = append(groups[registration.Group], doc)
+	}
+
+	for _, registration := range doctype.Registrations() {
+		group, exists := groups[registration.Group]
+		if !exists || registration.Reconcile == nil {
+			continue
+		}
+		registration.Reconcile(group)
+		delete(groups, registration.Group)
 	}
-	return processor, nil
 }
 
-func ProcessInvoice(data []byte) (doctype.Document, error) {
-	factory := NewProcessorFactory()
+// Collections 按注册表中的导出分组汇总文档，每个分组对应一个集合。
+// 此外还可以添加跨类型的报表集合（如差旅行程），报表集合会收到所有文档。
+type Collections struct {
+	groups  map[string]doctype.DocumentCollection
+	reports []report
+}
 
-	if !gjson.ValidBytes(data) {
-		return nil, fmt.Errorf("invalid json data")
+// report 跨类型的报表集合及其导出文件名
+type report struct {
+	output     string
+	collection doctype.DocumentCollection
+}
+
+func NewCollections() *Collections {
+	return &Collections{
+		groups: make(map[string]doctype.DocumentCollection),
 	}
+}
 
-	if gjson.GetBytes(data, "words_result_num").Int() != 1 {
-		return nil, fmt.Errorf("该程序只支持单张票据的识别")
+// AddReport 添加跨类型的报表集合，output 为不含扩展名的导出文件名
+func (c *Collections) AddReport(output string, collection doctype.DocumentCollection) {
+	c.reports = append(c.reports, report{output: output, collection: collection})
+}
+
+// Add 将文档加入其所属分组的集合与各报表中，集合在首次使用时创建
+func (c *Collections) Add(doc doctype.Document) error {
+	// 已确认重复报销的票据只在所属类型的表中标记，不计入行程、抵扣等跨类型报表，避免重复计算
+	if !doctype.IsDuplicate(doc) {
+		for _, report := range c.reports {
+			report.collection.Add(doc)
+		}
 	}
 
-	resultType := gjson.GetBytes(data, "words_result.0.type").String()
-	logger.Info("resultType: ", resultType)
-	processor, err := factory.GetProcessor(resultType)
+	registration, err := doctype.Resolve(doc.DocumentType())
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return processor.Process(data)
+	collection, exists := c.groups[registration.Group]
+	if !exists {
+		collection = registration.NewCollection()
+		c.groups[registration.Group] = collection
+	}
+	collection.Add(doc)
+	return nil
 }
 
-type DocumentFactory struct{}
-
-func (f *DocumentFactory) CreateCollection(docType doctype.DocumentType) doctype.DocumentCollection {
-	// TODO:注册其他处理器...
-	switch docType {
-	case doctype.TypeVatInvoice:
-		return &vat.Docs{}
-	case doctype.TypeTrainTicket:
-		return &train.Docs{}
-	default:
-		logger.Error("Unsupported document type: ", docType)
-		return nil
+// Export 按注册顺序导出所有非空集合，返回导出过程中出现的错误
+func (c *Collections) Export(exporter export.Exporter) []error {
+	errs := make([]error, 0)
+	exported := make(map[string]bool)
+	for _, registration := range doctype.Registrations() {
+		collection, exists := c.groups[registration.Group]
+		if !exists || exported[registration.Group] {
+			continue
+		}
+		exported[registration.Group] = true
+
+		sheets := collection.Sheets()
+		if len(sheets) == 0 {
+			continue
+		}
+		logger.Infof("Exporting %s to %s", registration.Name, registration.Output)
+		if err := exporter.Export(registration.Output, sheets); err != nil {
+			errs = append(errs, fmt.Errorf("export %s: %w", registration.Name, err))
+		}
+	}
+
+	// 报表依赖各类文档的数据，在所有集合之后导出
+	for _, report := range c.reports {
+		sheets := report.collection.Sheets()
+		if len(sheets) == 0 {
+			continue
+		}
+		if err := exporter.Export(report.output, sheets); err != nil {
+			errs = append(errs, fmt.Errorf("export %s: %w", report.output, err))
+		}
 	}
+	return errs
 }
@@ -0,0 +1,54 @@
+package proc
+
+import (
+	"FinDocOCR/doctype"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDoc struct {
+	Number string
+	doctype.DuplicateMark
+}
+
+func (d *testDoc) DocumentType() doctype.DocumentType { return "proc_test_doc" }
+func (d *testDoc) AmendData()                         {}
+
+type testProcessor struct{}
+
+func (p *testProcessor) Process(data []byte) (doctype.Document, error) { return &testDoc{}, nil }
+
+type testCollection struct {
+	docs []doctype.Document
+}
+
+func (c *testCollection) Add(doc doctype.Document) { c.docs = append(c.docs, doc) }
+func (c *testCollection) Sheets() []doctype.Sheet  { return nil }
+
+func TestCollectionsExcludeDuplicatesFromReports(t *testing.T) {
+	group := &testCollection{}
+	doctype.Register(doctype.Registration{
+		Type:          "proc_test_doc",
+		Processor:     &testProcessor{},
+		NewCollection: func() doctype.DocumentCollection { return group },
+	})
+
+	report := &testCollection{}
+	collections := NewCollections()
+	collections.AddReport("报表", report)
+
+	unique := &testDoc{Number: "001"}
+	duplicate := &testDoc{Number: "001"}
+	duplicate.MarkDuplicate("与本次运行的 a.jpg 重复")
+	similar := &testDoc{Number: "002"}
+	similar.MarkSimilar("与 a.jpg 图像相似，请核对")
+	for _, doc := range []doctype.Document{unique, duplicate, similar} {
+		require.NoError(t, collections.Add(doc))
+	}
+
+	// 重复的票据仍导出到所属类型的表中并标记，但不计入跨类型的报表
+	assert.Equal(t, []doctype.Document{unique, duplicate, similar}, group.docs)
+	assert.Equal(t, []doctype.Document{unique, similar}, report.docs)
+}
@@ -0,0 +1,246 @@
+package shopping
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/proc/field"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"strings"
+	"unicode"
+)
+
+var logger = config.GetLogger()
+
+// output 导出文件名
+const output = "购物小票处理结果"
+
+func init() {
+	processor := &Processor{}
+	for _, t := range []doctype.DocumentType{doctype.TypeShoppingReceipt, doctype.TypePosInvoice} {
+		doctype.Register(doctype.Registration{
+			Type:          t,
+			Name:          (&Doc{DocType: t}).SubTypeName(),
+			Processor:     processor,
+			Group:         "shopping_receipt",
+			NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+			Output:        output,
+		})
+	}
+}
+
+// Item 小票中的一行商品
+type Item struct {
+	Name      string
+	Quantity  string
+	UnitPrice string
+	Amount    string
+}
+
+// Doc represents shopping receipt and POS slip data
+type Doc struct {
+	DocType        doctype.DocumentType
+	Merchant       string
+	ReceiptNumber  string
+	Date           string
+	Time           string
+	CardLastDigits string
+	TotalAmount    string
+	Items          []Item
+	doctype.DuplicateMark
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	return fmt.Sprintf("DocType: %s, Merchant: %s, ReceiptNumber: %s, Date: %s, Time: %s, CardLastDigits: %s, TotalAmount: %s, Items: %d",
+		d.DocType, d.Merchant, d.ReceiptNumber, d.Date, d.Time, d.CardLastDigits, d.TotalAmount, len(d.Items))
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return d.DocType
+}
+
+// SubTypeName 返回小票类型的中文名称
+func (d *Doc) SubTypeName() string {
+	if d.DocType == doctype.TypePosInvoice {
+		return "POS小票"
+	}
+	return "购物小票"
+}
+
+// DocumentKey 小票号码只在商户内唯一，以商户、日期与小票号码标识一张小票
+func (d *Doc) DocumentKey() string {
+	if d.ReceiptNumber == "" {
+		return ""
+	}
+	return strings.Join([]string{d.Merchant, d.Date, d.ReceiptNumber}, "|")
+}
+
+func (d *Doc) AmendData() {
+	d.Date = field.NormalizeDate(d.Date)
+	d.TotalAmount = field.NormalizeMoney(d.TotalAmount)
+	d.CardLastDigits = lastDigits(d.CardLastDigits, 4)
+
+	for i := range d.Items {
+		d.Items[i].UnitPrice = field.NormalizeMoney(d.Items[i].UnitPrice)
+		d.Items[i].Amount = field.NormalizeMoney(d.Items[i].Amount)
+	}
+}
+
+// lastDigits 从卡号（通常带有 * 掩码）中取末尾 n 位数字
+func lastDigits(cardNumber string, n int) string {
+	digits := make([]rune, 0, n)
+	for _, r := range []rune(cardNumber) {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		} else {
+			// 掩码或空格之后重新计数，只保留最后一段连续数字
+			digits = digits[:0]
+		}
+	}
+	if len(digits) > n {
+		digits = digits[len(digits)-n:]
+	}
+	return string(digits)
+}
+
+// Processor 处理购物小票与POS小票
+type Processor struct{}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{
+		DocType: doctype.DocumentType(gjson.GetBytes(data, "words_result.0.type").String()),
+	}
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	d.Merchant = field.FirstWord(wordsResult, "shop_name", "MerchantName", "merchant_name")
+	d.ReceiptNumber = field.FirstWord(wordsResult, "receipt_num", "ReferenceNum", "VoucherNum")
+	d.Date = field.FirstWord(wordsResult, "consumption_date", "TransactionDate", "Date")
+	d.Time = field.FirstWord(wordsResult, "consumption_time", "TransactionTime", "Time")
+	d.CardLastDigits = field.FirstWord(wordsResult, "card_number", "CardNum", "CardNumber")
+	d.TotalAmount = field.FirstWord(wordsResult, "total_amount", "paid_amount", "Amount", "TotalAmount")
+	d.Items = parseItems(wordsResult)
+	d.AmendData()
+
+	logger.Info("Shopping receipt data: ", d)
+	return &d, nil
+}
+
+// parseItems 解析商品明细。购物小票以 table 数组返回明细，
+// 其余版式则以多个等长的数组分别返回名称、数量和价格。
+func parseItems(wordsResult gjson.Result) []Item {
+	items := make([]Item, 0)
+
+	if table := wordsResult.Get("table"); table.IsArray() {
+		for _, row := range table.Array() {
+			item := Item{
+				Name:      field.Word(row, "product"),
+				Quantity:  field.Word(row, "quantity"),
+				UnitPrice: field.Word(row, "unit_price"),
+				Amount:    field.Word(row, "subtotal_amount"),
+			}
+			if item.Name != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	names := wordsResult.Get("CommodityName").Array()
+	for i, name := range names {
+		item := Item{
+			Name:      strings.TrimSpace(name.Get("word").String()),
+			Quantity:  wordsResult.Get(fmt.Sprintf("CommodityNum.%d.word", i)).String(),
+			UnitPrice: wordsResult.Get(fmt.Sprintf("CommodityPrice.%d.word", i)).String(),
+			Amount:    wordsResult.Get(fmt.Sprintf("CommodityAmount.%d.word", i)).String(),
+		}
+		if item.Name != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type Docs []Doc
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	*docs = append(*docs, *d)
+}
+
+var (
+	// receiptColumns 小票汇总表的导出列
+	receiptColumns = []doctype.Column{
+		{Key: "sub_type", Header: "票据类型"},
+		{Key: "merchant", Header: "商户名称"},
+		{Key: "receipt_number", Header: "小票号码", Role: doctype.RoleNumber},
+		{Key: "date", Header: "日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+		{Key: "time", Header: "时间"},
+		{Key: "card_last_digits", Header: "卡号后四位"},
+		{Key: "total_amount", Header: "合计金额", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+		{Key: "item_count", Header: "商品数", Type: doctype.ColumnNumber},
+		{Key: "duplicate", Header: "重复", Warning: true},
+		{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+		{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+	}
+
+	// itemColumns 商品明细表的导出列，通过商户、日期与小票号码与汇总表对应。
+	// 序号只在一次运行内唯一，追加到已有表格时会与之前的记录冲突，因此不用于关联
+	itemColumns = []doctype.Column{
+		{Key: "merchant", Header: "商户名称"},
+		{Key: "date", Header: "日期", Type: doctype.ColumnDate},
+		{Key: "receipt_number", Header: "小票号码"},
+		{Key: "line", Header: "行号", Type: doctype.ColumnNumber},
+		{Key: "name", Header: "商品名称"},
+		{Key: "quantity", Header: "数量"},
+		{Key: "unit_price", Header: "单价", Type: doctype.ColumnMoney},
+		{Key: "amount", Header: "金额", Type: doctype.ColumnMoney},
+	}
+)
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	receiptRows := make([][]interface{}, 0, len(*docs))
+	itemRows := make([][]interface{}, 0)
+	for _, d := range *docs {
+		receiptRows = append(receiptRows, []interface{}{
+			d.SubTypeName(),
+			d.Merchant,
+			d.ReceiptNumber,
+			d.Date,
+			d.Time,
+			d.CardLastDigits,
+			d.TotalAmount,
+			len(d.Items),
+			d.DuplicateNote(),
+			d.SourcePath(),
+			d.Thumbnail(),
+		})
+
+		for i, item := range d.Items {
+			itemRows = append(itemRows, []interface{}{
+				d.Merchant,
+				d.Date,
+				d.ReceiptNumber,
+				i + 1,
+				item.Name,
+				item.Quantity,
+				item.UnitPrice,
+				item.Amount,
+			})
+		}
+	}
+
+	return []doctype.Sheet{
+		{Name: "小票", Columns: receiptColumns, Rows: receiptRows, Keys: []string{"merchant", "date", "receipt_number"}},
+		{Name: "商品明细", Columns: itemColumns, Rows: itemRows, Keys: []string{"merchant", "date", "receipt_number", "line"}},
+	}
+}
@@ -0,0 +1,105 @@
+package shopping
+
+import (
+	"FinDocOCR/doctype"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessShoppingReceipt(t *testing.T) {
+	// 购物小票以 table 数组返回商品明细
+	response := `{
+		"log_id": 1784512093456789101,
+		"words_result_num": 1,
+		"words_result": [{
+			"probability": 0.9876,
+			"type": "shopping_receipt",
+			"result": {
+				"shop_name": [{"word": "华润万家（科技园店）"}],
+				"receipt_num": [{"word": "0023145678"}],
+				"consumption_date": [{"word": "2024年05月20日"}],
+				"consumption_time": [{"word": "18:42:07"}],
+				"card_number": [{"word": "6222 **** **** 1234"}],
+				"total_amount": [{"word": "￥58.40"}],
+				"table": [
+					{
+						"product": {"word": "矿泉水550ml"},
+						"quantity": {"word": "2"},
+						"unit_price": {"word": "2.00"},
+						"subtotal_amount": {"word": "4.00"}
+					},
+					{
+						"product": {"word": "打印纸A4"},
+						"quantity": {"word": "1"},
+						"unit_price": {"word": "￥54.40"},
+						"subtotal_amount": {"word": "￥54.40"}
+					}
+				]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, doctype.DocumentType(doctype.TypeShoppingReceipt), d.DocType)
+	assert.Equal(t, "购物小票", d.SubTypeName())
+	assert.Equal(t, "华润万家（科技园店）", d.Merchant)
+	assert.Equal(t, "2024.05.20", d.Date)
+	assert.Equal(t, "1234", d.CardLastDigits)
+	assert.Equal(t, "58.40", d.TotalAmount)
+	require.Len(t, d.Items, 2)
+	assert.Equal(t, Item{Name: "打印纸A4", Quantity: "1", UnitPrice: "54.40", Amount: "54.40"}, d.Items[1])
+	assert.Equal(t, "华润万家（科技园店）|2024.05.20|0023145678", d.DocumentKey())
+
+	docs := &Docs{}
+	docs.Add(doc)
+	sheets := docs.Sheets()
+	require.Len(t, sheets, 2)
+	assert.Equal(t, 2, sheets[0].Record(0)["item_count"])
+	require.Len(t, sheets[1].Rows, 2)
+	assert.Equal(t, "矿泉水550ml", sheets[1].Record(0)["name"])
+	// 明细通过小票的唯一键与小票对应，追加到已有表格时按键去重
+	second := sheets[1].Record(1)
+	assert.Equal(t, "华润万家（科技园店）", second["merchant"])
+	assert.Equal(t, "2024.05.20", second["date"])
+	assert.Equal(t, "0023145678", second["receipt_number"])
+	assert.Equal(t, 2, second["line"])
+	assert.Equal(t, []string{"merchant", "date", "receipt_number", "line"}, sheets[1].Keys)
+}
+
+func TestProcessPosInvoice(t *testing.T) {
+	// POS 小票使用驼峰命名，没有商品明细
+	response := `{
+		"log_id": 1784512093456789102,
+		"words_result_num": 1,
+		"words_result": [{
+			"type": "pos_invoice",
+			"result": {
+				"MerchantName": [{"word": "深圳市某某酒店管理有限公司"}],
+				"ReferenceNum": [{"word": "301234567890"}],
+				"TransactionDate": [{"word": "2024/05/21"}],
+				"CardNum": [{"word": "622588******6789"}],
+				"Amount": [{"word": "RMB 468.00"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "POS小票", d.SubTypeName())
+	assert.Equal(t, "301234567890", d.ReceiptNumber)
+	assert.Equal(t, "6789", d.CardLastDigits)
+	assert.Empty(t, d.Items)
+}
+
+func TestLastDigits(t *testing.T) {
+	assert.Equal(t, "1234", lastDigits("6222 **** **** 1234", 4))
+	assert.Equal(t, "89", lastDigits("****89", 4))
+	assert.Equal(t, "", lastDigits("", 4))
+}
@@ -0,0 +1,461 @@
+package air
+
+import (
+	"FinDocOCR/compliance"
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/proc/field"
+	"FinDocOCR/proc/ticket/train/station"
+	"FinDocOCR/tax"
+	"FinDocOCR/travel"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var logger = config.GetLogger()
+
+// output 导出文件名
+const output = "机票处理结果"
+
+func init() {
+	doctype.Register(doctype.Registration{
+		Type:          doctype.TypeAirTicket,
+		Name:          "航空运输电子客票行程单",
+		Processor:     &Processor{},
+		NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+		Output:        output,
+	})
+	compliance.Provide(compliance.FieldPassenger, compliance.FieldDate, compliance.FieldNumber,
+		compliance.FieldCabinClass, compliance.FieldFare, compliance.FieldGrade, compliance.FieldDepartment)
+}
+
+// 舱位等级
+const (
+	CabinFirst    = "头等舱"
+	CabinBusiness = "公务舱"
+	CabinEconomy  = "经济舱"
+)
+
+// cabinCodes 行程单上的舱位代码与舱位等级的对应关系，未列出的代码均为经济舱
+var cabinCodes = map[string]string{
+	"F": CabinFirst, "A": CabinFirst, "P": CabinFirst,
+	"C": CabinBusiness, "D": CabinBusiness, "I": CabinBusiness, "J": CabinBusiness, "Z": CabinBusiness, "R": CabinBusiness,
+}
+
+// cabinClass 将舱位代码或文字转换为舱位等级，无法识别时返回空字符串
+func cabinClass(class string) string {
+	class = strings.ToUpper(strings.TrimSpace(class))
+	switch {
+	case class == "":
+		return ""
+	case strings.Contains(class, "头等"):
+		return CabinFirst
+	case strings.Contains(class, "公务"), strings.Contains(class, "商务"):
+		return CabinBusiness
+	case strings.Contains(class, "经济"):
+		return CabinEconomy
+	}
+	if len(class) != 1 || class[0] < 'A' || class[0] > 'Z' {
+		return ""
+	}
+	if cabin, ok := cabinCodes[class]; ok {
+		return cabin
+	}
+	return CabinEconomy
+}
+
+var (
+	// datePattern 行程单上的航班日期，如"2024-03-05"、"2024年03月05日"
+	datePattern = regexp.MustCompile(`(\d{4})\D(\d{1,2})\D(\d{1,2})`)
+	// shortDatePattern 纸质行程单上不带年份的航班日期，如"05MAR"
+	shortDatePattern = regexp.MustCompile(`^(\d{1,2})([A-Za-z]{3})$`)
+	timePattern      = regexp.MustCompile(`^(\d{1,2}):?(\d{2})$`)
+)
+
+// Segment 行程单上的一个航段
+type Segment struct {
+	From   string
+	To     string
+	Flight string
+	// Class 舱位等级（座位等级）原文，如"Y"、"经济舱"
+	Class string
+	Date  string
+	Time  string
+}
+
+// Doc represents air itinerary data
+type Doc struct {
+	Name     string
+	IDNum    string
+	Segments []Segment
+	Carrier  string
+	// Fare 票价，FuelSurcharge 燃油附加费，DevFund 民航发展基金，OtherTax 其他税费，Total 合计
+	Fare          string
+	FuelSurcharge string
+	DevFund       string
+	OtherTax      string
+	Total         string
+	// TicketNum 电子客票号码，SerialNumber 印刷序号
+	TicketNum    string
+	SerialNumber string
+	IssueDate    string
+	// 乘机人关联的员工信息
+	employee.Assignment
+	// 差旅合规检查结果
+	compliance.Flags
+	doctype.DuplicateMark
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	return fmt.Sprintf("Name: %s, IDNum: %s, Segments: %v, Carrier: %s, Fare: %s, FuelSurcharge: %s, DevFund: %s, OtherTax: %s, Total: %s, "+
+		"TicketNum: %s, SerialNumber: %s, IssueDate: %s",
+		d.Name, d.IDNum, d.Segments, d.Carrier, d.Fare, d.FuelSurcharge, d.DevFund, d.OtherTax, d.Total,
+		d.TicketNum, d.SerialNumber, d.IssueDate)
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return doctype.TypeAirTicket
+}
+
+// PersonName 返回用于匹配员工的乘机人姓名
+func (d *Doc) PersonName() string {
+	return d.Name
+}
+
+// Facts 返回合规检查使用的字段。多航段时舱位等级取最高的一段，以便限制舱位的规则不会遗漏
+func (d *Doc) Facts() compliance.Facts {
+	facts := compliance.Facts{
+		compliance.FieldPassenger:  d.Name,
+		compliance.FieldFare:       strconv.FormatFloat(d.fare(), 'f', 2, 64),
+		compliance.FieldGrade:      d.Grade,
+		compliance.FieldDepartment: d.Department,
+	}
+
+	flights := make([]string, 0, len(d.Segments))
+	rank := map[string]int{CabinEconomy: 1, CabinBusiness: 2, CabinFirst: 3}
+	cabin := ""
+	for _, s := range d.Segments {
+		flights = append(flights, s.Flight)
+		if c := cabinClass(s.Class); rank[c] > rank[cabin] {
+			cabin = c
+		}
+	}
+	facts[compliance.FieldNumber] = strings.Join(flights, "/")
+	facts[compliance.FieldCabinClass] = cabin
+	if len(d.Segments) > 0 {
+		facts[compliance.FieldDate] = d.Segments[0].Date
+	}
+	return facts
+}
+
+// DocumentKey 以电子客票号码标识一张行程单
+func (d *Doc) DocumentKey() string {
+	return d.TicketNum
+}
+
+func (d *Doc) AmendData() {
+	d.Name = strings.Join(strings.Fields(d.Name), "")
+	d.TicketNum = strings.ReplaceAll(strings.TrimSpace(d.TicketNum), " ", "")
+	d.IssueDate = normalizeDate(d.IssueDate, "")
+
+	for i := range d.Segments {
+		s := &d.Segments[i]
+		s.Flight = strings.ToUpper(strings.ReplaceAll(s.Flight, " ", ""))
+		s.Date = normalizeDate(s.Date, d.IssueDate)
+		s.Time = normalizeTime(s.Time)
+	}
+
+	for _, amount := range []*string{&d.Fare, &d.FuelSurcharge, &d.DevFund, &d.OtherTax, &d.Total} {
+		*amount = normalizeMoney(*amount)
+	}
+}
+
+// normalizeDate 将航班日期统一为"2024.03.05"。纸质行程单的航班日期不带年份（如"05MAR"），
+// 按填开日期的年份补齐，跨年的航班（12 月填开、1 月乘机）顺延一年
+func normalizeDate(s, issueDate string) string {
+	s = strings.TrimSpace(s)
+	if match := datePattern.FindStringSubmatch(s); match != nil {
+		return fmt.Sprintf("%s.%02s.%02s", match[1], match[2], match[3])
+	}
+	if match := shortDatePattern.FindStringSubmatch(s); match != nil {
+		issued, err := time.Parse("2006.01.02", issueDate)
+		if err != nil {
+			return s
+		}
+		month := strings.ToUpper(match[2][:1]) + strings.ToLower(match[2][1:])
+		date, err := time.Parse("02Jan2006", fmt.Sprintf("%02s%s%d", match[1], month, issued.Year()))
+		if err != nil {
+			return s
+		}
+		if date.Before(issued.AddDate(0, 0, -1)) {
+			date = date.AddDate(1, 0, 0)
+		}
+		return date.Format("2006.01.02")
+	}
+	return s
+}
+
+// normalizeTime 将"1830"、"18:30"统一为"18:30"
+func normalizeTime(s string) string {
+	match := timePattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return strings.TrimSpace(s)
+	}
+	return fmt.Sprintf("%02s:%s", match[1], match[2])
+}
+
+// normalizeMoney 去除金额中的币种与符号，免收的税费（如"免"、"EXEMPT"）记为空
+func normalizeMoney(s string) string {
+	s = strings.TrimPrefix(strings.ToUpper(field.NormalizeMoney(s)), "CNY")
+	s = strings.TrimSpace(s)
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return ""
+	}
+	return s
+}
+
+// amount 返回金额的数值
+func amount(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// fare 行程单的交通费：合计金额，未识别到合计时为各项之和
+func (d *Doc) fare() float64 {
+	if d.Total != "" {
+		return amount(d.Total)
+	}
+	return amount(d.Fare) + amount(d.FuelSurcharge) + amount(d.DevFund) + amount(d.OtherTax)
+}
+
+// TravelLegs 将每个航段转换为行程段，交通费计入第一个航段。
+// 行程单上没有到达时间，抵达日期按起飞日期计算
+func (d *Doc) TravelLegs() []travel.Leg {
+	legs := make([]travel.Leg, 0, len(d.Segments))
+	for i, s := range d.Segments {
+		departure, err := time.Parse("2006.01.02 15:04", s.Date+" "+s.Time)
+		if err != nil {
+			departure, err = time.Parse("2006.01.02", s.Date)
+			if err != nil {
+				logger.Warnf("Air ticket %s: invalid flight date %q", d.TicketNum, s.Date)
+				continue
+			}
+		}
+
+		leg := travel.Leg{
+			Passenger:       d.Name,
+			Mode:            travel.ModeAir,
+			Number:          s.Flight,
+			Departure:       departure,
+			Arrival:         departure,
+			Origin:          s.From,
+			Destination:     s.To,
+			OriginCity:      station.City(s.From),
+			DestinationCity: station.City(s.To),
+		}
+		if i == 0 {
+			leg.Fare = d.fare()
+		}
+		legs = append(legs, leg)
+	}
+	return legs
+}
+
+// TransportDeduction 返回计算进项税抵扣所需的信息，抵扣基数为票价与燃油附加费之和，
+// 民航发展基金不参与计算
+func (d *Doc) TransportDeduction() (tax.Transport, bool) {
+	if len(d.Segments) == 0 {
+		return tax.Transport{}, false
+	}
+	date, err := time.Parse("2006.01.02", d.Segments[0].Date)
+	if err != nil {
+		return tax.Transport{}, false
+	}
+
+	remarks := make([]string, 0, 2)
+	if d.IDNum == "" {
+		remarks = append(remarks, "未识别到旅客身份信息")
+	}
+	base := amount(d.Fare) + amount(d.FuelSurcharge)
+	if d.Fare == "" {
+		base = d.fare()
+		remarks = append(remarks, "未识别到票价，按合计金额计算")
+	}
+
+	return tax.Transport{
+		Category:  tax.CategoryAir,
+		Date:      date,
+		Passenger: d.Name,
+		Number:    d.TicketNum,
+		Base:      base,
+		Remark:    strings.Join(remarks, "；"),
+	}, true
+}
+
+// Processor 处理航空运输电子客票行程单
+type Processor struct{}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{}
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	d.Name = field.FirstWord(wordsResult, "name", "passenger_name")
+	d.IDNum = field.FirstWord(wordsResult, "id_no", "id_num", "ID_num")
+	d.Carrier = field.FirstWord(wordsResult, "carrier")
+	d.Fare = field.FirstWord(wordsResult, "fare")
+	d.FuelSurcharge = field.FirstWord(wordsResult, "fuel_surcharge")
+	d.DevFund = field.FirstWord(wordsResult, "dev_fund", "caac_development_fund")
+	d.OtherTax = field.FirstWord(wordsResult, "other_tax")
+	d.Total = field.FirstWord(wordsResult, "total", "total_fare", "ticket_rates")
+	d.TicketNum = field.FirstWord(wordsResult, "ticket_number", "e_ticket_number", "ticket_num")
+	d.SerialNumber = field.FirstWord(wordsResult, "serial_number")
+	d.IssueDate = field.FirstWord(wordsResult, "issued_date", "date_of_issue")
+	d.Segments = parseSegments(wordsResult)
+	d.AmendData()
+
+	logger.Info("Air ticket data: ", d)
+	return &d, nil
+}
+
+// parseSegments 解析航段。多航段的行程单中，出发站、到达站、航班号等字段按航段顺序返回多个识别结果
+func parseSegments(wordsResult gjson.Result) []Segment {
+	words := func(keys ...string) []string {
+		for _, key := range keys {
+			values := wordsResult.Get(key).Array()
+			if len(values) == 0 {
+				continue
+			}
+			result := make([]string, 0, len(values))
+			for _, v := range values {
+				result = append(result, strings.TrimSpace(v.Get("word").String()))
+			}
+			return result
+		}
+		return nil
+	}
+	at := func(values []string, i int) string {
+		if i < len(values) {
+			return values[i]
+		}
+		return ""
+	}
+
+	from := words("starting_station", "from")
+	to := words("destination_station", "to")
+	flights := words("flight", "flight_number")
+	classes := words("class", "seat_class")
+	dates := words("date", "flight_date")
+	times := words("time", "flight_time")
+
+	segments := make([]Segment, 0, len(from))
+	for i := range from {
+		if from[i] == "" && at(to, i) == "" {
+			continue
+		}
+		segments = append(segments, Segment{
+			From:   from[i],
+			To:     at(to, i),
+			Flight: at(flights, i),
+			Class:  at(classes, i),
+			Date:   at(dates, i),
+			Time:   at(times, i),
+		})
+	}
+	return segments
+}
+
+type Docs []Doc
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	*docs = append(*docs, *d)
+}
+
+// columns 导出列。多航段的行程单占一行，起始地为第一个航段的出发站，目的地为最后一个航段的到达站，
+// 航班号与舱位等级按航段顺序以"/"连接
+var columns = []doctype.Column{
+	{Key: "name", Header: "*人员", Role: doctype.RolePerson},
+	{Key: "start_date", Header: "*出发日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+	{Key: "starting_station", Header: "*起始地"},
+	{Key: "destination_station", Header: "*目的地"},
+	{Key: "total", Header: "*票价", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+	{Key: "flight", Header: "航班号"},
+	{Key: "start_time", Header: "起飞时间"},
+	{Key: "class", Header: "舱位等级"},
+	{Key: "carrier", Header: "承运人"},
+	{Key: "fare", Header: "票价", Type: doctype.ColumnMoney},
+	{Key: "fuel_surcharge", Header: "燃油附加费", Type: doctype.ColumnMoney},
+	{Key: "dev_fund", Header: "民航发展基金", Type: doctype.ColumnMoney},
+	{Key: "other_tax", Header: "其他税费", Type: doctype.ColumnMoney},
+	{Key: "ticket_num", Header: "电子客票号码", Role: doctype.RoleNumber},
+	{Key: "serial_number", Header: "印刷序号"},
+	{Key: "id_num", Header: "身份证号"},
+	{Key: "employee_id", Header: "工号"},
+	{Key: "department", Header: "部门"},
+	{Key: "cost_center", Header: "成本中心"},
+	{Key: "match_status", Header: "员工匹配", Warning: true},
+	{Key: "violations", Header: "合规检查", Warning: true},
+	{Key: "duplicate", Header: "重复", Warning: true},
+	{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+}
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, d := range *docs {
+		var first, last Segment
+		flights := make([]string, 0, len(d.Segments))
+		classes := make([]string, 0, len(d.Segments))
+		if len(d.Segments) > 0 {
+			first, last = d.Segments[0], d.Segments[len(d.Segments)-1]
+		}
+		for _, s := range d.Segments {
+			flights = append(flights, s.Flight)
+			classes = append(classes, s.Class)
+		}
+
+		rows = append(rows, []interface{}{
+			d.Name,
+			first.Date,
+			first.From,
+			last.To,
+			strconv.FormatFloat(d.fare(), 'f', 2, 64),
+			strings.Join(flights, "/"),
+			first.Time,
+			strings.Join(classes, "/"),
+			d.Carrier,
+			d.Fare,
+			d.FuelSurcharge,
+			d.DevFund,
+			d.OtherTax,
+			d.TicketNum,
+			d.SerialNumber,
+			d.IDNum,
+			d.EmployeeID,
+			d.Department,
+			d.CostCenter,
+			d.MatchStatus,
+			d.ViolationSummary(),
+			d.DuplicateNote(),
+			d.SourcePath(),
+			d.Thumbnail(),
+		})
+	}
+
+	return []doctype.Sheet{{Name: "机票", Columns: columns, Rows: rows, Keys: []string{"ticket_num"}}}
+}
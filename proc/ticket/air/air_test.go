@@ -0,0 +1,131 @@
+package air
+
+import (
+	"FinDocOCR/compliance"
+	"FinDocOCR/doctype"
+	"FinDocOCR/tax"
+	"FinDocOCR/travel"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func process(t *testing.T, result string) *Doc {
+	response := `{"words_result_num": 1, "words_result": [{"type": "air_ticket", "result": ` + result + `}]}`
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+	return doc.(*Doc)
+}
+
+func TestProcessItinerary(t *testing.T) {
+	d := process(t, `{
+		"name": [{"word": "张三"}],
+		"id_no": [{"word": "110101199001011234"}],
+		"starting_station": [{"word": "长沙黄花"}],
+		"destination_station": [{"word": "北京首都"}],
+		"flight": [{"word": "ca 1352"}],
+		"carrier": [{"word": "国航"}],
+		"class": [{"word": "Y"}],
+		"date": [{"word": "05MAR"}],
+		"time": [{"word": "1800"}],
+		"fare": [{"word": "CNY 1200.00"}],
+		"fuel_surcharge": [{"word": "CNY 60.00"}],
+		"dev_fund": [{"word": "CNY 50.00"}],
+		"other_tax": [{"word": "免"}],
+		"ticket_rates": [{"word": "CNY 1310.00"}],
+		"ticket_number": [{"word": "999 1234567890"}],
+		"serial_number": [{"word": "12345678901"}],
+		"issued_date": [{"word": "2024-02-28"}]
+	}`)
+
+	assert.Equal(t, "1310.00", d.Total)
+	assert.Equal(t, "", d.OtherTax)
+	assert.Equal(t, "9991234567890", d.DocumentKey())
+	require.Len(t, d.Segments, 1)
+	assert.Equal(t, Segment{From: "长沙黄花", To: "北京首都", Flight: "CA1352", Class: "Y", Date: "2024.03.05", Time: "18:00"}, d.Segments[0])
+
+	legs := d.TravelLegs()
+	require.Len(t, legs, 1)
+	assert.Equal(t, travel.ModeAir, legs[0].Mode)
+	assert.Equal(t, "长沙", legs[0].OriginCity)
+	assert.Equal(t, "北京", legs[0].DestinationCity)
+	assert.Equal(t, 1310.0, legs[0].Fare)
+
+	// 抵扣基数为票价与燃油附加费之和
+	transport, ok := d.TransportDeduction()
+	require.True(t, ok)
+	assert.Equal(t, tax.CategoryAir, transport.Category)
+	assert.Equal(t, 1260.0, transport.Base)
+	assert.Equal(t, 104.04, transport.Deductible())
+	assert.Empty(t, transport.Remark)
+
+	docs := &Docs{}
+	docs.Add(d)
+	record := docs.Sheets()[0].Record(0)
+	assert.Equal(t, "2024.03.05", record["start_date"])
+	assert.Equal(t, "1310.00", record["total"])
+}
+
+func TestMultiSegmentItinerary(t *testing.T) {
+	// 中转的行程单有多个航段，交通费只计入第一个航段
+	d := process(t, `{
+		"name": [{"word": "李四"}],
+		"starting_station": [{"word": "上海虹桥"}, {"word": "广州白云"}],
+		"destination_station": [{"word": "广州白云"}, {"word": "深圳宝安"}],
+		"flight": [{"word": "MU5301"}, {"word": "CZ3001"}],
+		"date": [{"word": "2024-12-31"}, {"word": "2025-01-01"}],
+		"time": [{"word": "08:00"}, {"word": "09:30"}],
+		"fare": [{"word": "1500.00"}],
+		"fuel_surcharge": [{"word": "80.00"}],
+		"dev_fund": [{"word": "100.00"}],
+		"ticket_number": [{"word": "7812345678901"}]
+	}`)
+
+	require.Len(t, d.Segments, 2)
+	legs := d.TravelLegs()
+	require.Len(t, legs, 2)
+	assert.Equal(t, 1680.0, legs[0].Fare)
+	assert.Equal(t, 0.0, legs[1].Fare)
+
+	trips := travel.BuildTrips(legs)
+	require.Len(t, trips, 1)
+	assert.Equal(t, []string{"广州", "深圳"}, trips[0].Destinations())
+
+	docs := &Docs{}
+	docs.Add(d)
+	record := docs.Sheets()[0].Record(0)
+	assert.Equal(t, "上海虹桥", record["starting_station"])
+	assert.Equal(t, "深圳宝安", record["destination_station"])
+	assert.Equal(t, "MU5301/CZ3001", record["flight"])
+}
+
+func TestNormalizeDate(t *testing.T) {
+	assert.Equal(t, "2024.03.05", normalizeDate("2024年3月5日", ""))
+	// 12 月填开、1 月乘机
+	assert.Equal(t, "2025.01.02", normalizeDate("02JAN", "2024.12.28"))
+	// 没有填开日期时无法补齐年份
+	assert.Equal(t, "02JAN", normalizeDate("02JAN", ""))
+}
+
+func TestCabinClassFacts(t *testing.T) {
+	assert.Equal(t, CabinFirst, cabinClass("f"))
+	assert.Equal(t, CabinBusiness, cabinClass("公务舱"))
+	assert.Equal(t, CabinEconomy, cabinClass("Y"))
+	assert.Equal(t, "", cabinClass("??"))
+
+	// 多航段时取最高的舱位等级
+	d := &Doc{Name: "张三", Total: "3200.00", Segments: []Segment{
+		{Flight: "CA1", Class: "Y", Date: "2024.03.05"},
+		{Flight: "CA2", Class: "C", Date: "2024.03.05"},
+	}}
+	facts := d.Facts()
+	assert.Equal(t, CabinBusiness, facts[compliance.FieldCabinClass])
+	assert.Equal(t, "CA1/CA2", facts[compliance.FieldNumber])
+	assert.Equal(t, "3200.00", facts[compliance.FieldFare])
+
+	rules, err := compliance.ParseRules([]byte(`rules: [{name: 限乘经济舱, when: [{field: cabin_class, op: ne, value: 经济舱}]}]`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, rules.Check([]doctype.Document{d}))
+	assert.Equal(t, "限乘经济舱", d.ViolationSummary())
+}
@@ -0,0 +1,208 @@
+package passenger
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/proc/field"
+	"FinDocOCR/tax"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var logger = config.GetLogger()
+
+// output 导出文件名
+const output = "汽车船票处理结果"
+
+// subTypeNames 各类客票在导出表中显示的名称
+var subTypeNames = map[doctype.DocumentType]string{
+	doctype.TypeBusTicket:   "汽车票",
+	doctype.TypeFerryTicket: "船票",
+}
+
+func init() {
+	// 汽车票与船票字段基本一致，共用同一个处理器并汇总到同一张导出表中
+	processor := &Processor{}
+	for _, t := range []doctype.DocumentType{doctype.TypeBusTicket, doctype.TypeFerryTicket} {
+		doctype.Register(doctype.Registration{
+			Type:          t,
+			Name:          subTypeNames[t],
+			Processor:     processor,
+			Group:         "passenger_ticket",
+			NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+			Output:        output,
+		})
+	}
+}
+
+// Doc represents bus and ferry ticket data
+type Doc struct {
+	DocType            doctype.DocumentType
+	Name               string
+	IDNum              string
+	Date               string
+	Time               string
+	StartingStation    string
+	DestinationStation string
+	Amount             string
+	InvoiceCode        string
+	InvoiceNum         string
+	// 乘客关联的员工信息
+	employee.Assignment
+	doctype.DuplicateMark
+	doctype.Source
+}
+
+func (d *Doc) String() string {
+	return fmt.Sprintf("DocType: %s, Name: %s, IDNum: %s, Date: %s, Time: %s, StartingStation: %s, DestinationStation: %s, Amount: %s, InvoiceCode: %s, InvoiceNum: %s",
+		d.DocType, d.Name, d.IDNum, d.Date, d.Time, d.StartingStation, d.DestinationStation, d.Amount, d.InvoiceCode, d.InvoiceNum)
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return d.DocType
+}
+
+// SubTypeName 返回客票类型的中文名称
+func (d *Doc) SubTypeName() string {
+	if name, ok := subTypeNames[d.DocType]; ok {
+		return name
+	}
+	return string(d.DocType)
+}
+
+// PersonName 返回用于匹配员工的乘客姓名
+func (d *Doc) PersonName() string {
+	return d.Name
+}
+
+// DocumentKey 以发票代码与发票号码标识一张客票
+func (d *Doc) DocumentKey() string {
+	if d.InvoiceNum == "" {
+		return ""
+	}
+	return d.InvoiceCode + "-" + d.InvoiceNum
+}
+
+func (d *Doc) AmendData() {
+	d.Name = strings.Join(strings.Fields(d.Name), "")
+	d.Date = field.NormalizeDate(strings.NewReplacer("-", ".", "/", ".").Replace(d.Date))
+	d.Amount = field.NormalizeMoney(d.Amount)
+}
+
+// TransportDeduction 返回计算进项税抵扣所需的信息。公路、水路客票只有注明旅客身份信息的才能抵扣，
+// 未识别到身份信息时仍然计算，并在备注中提示会计人员核对
+func (d *Doc) TransportDeduction() (tax.Transport, bool) {
+	date, err := time.Parse("2006.01.02", d.Date)
+	if err != nil {
+		return tax.Transport{}, false
+	}
+	base, err := strconv.ParseFloat(d.Amount, 64)
+	if err != nil {
+		return tax.Transport{}, false
+	}
+
+	remark := ""
+	if d.Name == "" || d.IDNum == "" {
+		remark = "未识别到旅客身份信息"
+	}
+	return tax.Transport{
+		Category:  tax.CategoryRoadWater,
+		Date:      date,
+		Passenger: d.Name,
+		Number:    d.InvoiceNum,
+		Base:      base,
+		Remark:    remark,
+	}, true
+}
+
+// Processor 处理汽车票与船票
+type Processor struct{}
+
+func (p *Processor) Process(data []byte) (doctype.Document, error) {
+	d := Doc{
+		DocType: doctype.DocumentType(gjson.GetBytes(data, "words_result.0.type").String()),
+	}
+	wordsResult := gjson.GetBytes(data, "words_result.0.result")
+	if !wordsResult.Exists() {
+		errCode := gjson.GetBytes(data, "error_code").String()
+		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
+	}
+
+	d.Name = field.FirstWord(wordsResult, "name", "passenger_name")
+	d.IDNum = field.FirstWord(wordsResult, "id_num", "ID_num", "id_card", "ID_card")
+	d.Date = field.FirstWord(wordsResult, "date", "Date")
+	d.Time = field.FirstWord(wordsResult, "time", "Time")
+	d.StartingStation = field.FirstWord(wordsResult, "starting_station", "StartingStation")
+	d.DestinationStation = field.FirstWord(wordsResult, "destination_station", "DestinationStation")
+	d.Amount = field.FirstWord(wordsResult, "amount", "fare", "ticket_rates", "Amount")
+	d.InvoiceCode = field.FirstWord(wordsResult, "invoice_code", "InvoiceCode")
+	d.InvoiceNum = field.FirstWord(wordsResult, "invoice_num", "InvoiceNum", "ticket_num")
+	d.AmendData()
+
+	logger.Info("Passenger ticket data: ", d)
+	return &d, nil
+}
+
+type Docs []Doc
+
+func (docs *Docs) Add(doc doctype.Document) {
+	d, ok := doc.(*Doc)
+	if !ok {
+		logger.Error("Failed to assert Doc type")
+		return
+	}
+
+	*docs = append(*docs, *d)
+}
+
+// columns 导出列
+var columns = []doctype.Column{
+	{Key: "sub_type", Header: "票据类型"},
+	{Key: "name", Header: "人员", Role: doctype.RolePerson},
+	{Key: "date", Header: "乘车日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+	{Key: "time", Header: "时间"},
+	{Key: "starting_station", Header: "起始地"},
+	{Key: "destination_station", Header: "目的地"},
+	{Key: "amount", Header: "票价", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+	{Key: "invoice_code", Header: "发票代码"},
+	{Key: "invoice_num", Header: "发票号码", Role: doctype.RoleNumber},
+	{Key: "id_num", Header: "身份证号"},
+	{Key: "employee_id", Header: "工号"},
+	{Key: "department", Header: "部门"},
+	{Key: "cost_center", Header: "成本中心"},
+	{Key: "match_status", Header: "员工匹配", Warning: true},
+	{Key: "duplicate", Header: "重复", Warning: true},
+	{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+}
+
+func (docs *Docs) Sheets() []doctype.Sheet {
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, d := range *docs {
+		rows = append(rows, []interface{}{
+			d.SubTypeName(),
+			d.Name,
+			d.Date,
+			d.Time,
+			d.StartingStation,
+			d.DestinationStation,
+			d.Amount,
+			d.InvoiceCode,
+			d.InvoiceNum,
+			d.IDNum,
+			d.EmployeeID,
+			d.Department,
+			d.CostCenter,
+			d.MatchStatus,
+			d.DuplicateNote(),
+			d.SourcePath(),
+			d.Thumbnail(),
+		})
+	}
+
+	return []doctype.Sheet{{Name: "汽车船票", Columns: columns, Rows: rows, Keys: []string{"invoice_code", "invoice_num"}}}
+}
@@ -0,0 +1,88 @@
+package passenger
+
+import (
+	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/tax"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessBusTicket(t *testing.T) {
+	response := `{
+		"log_id": 1784512093456789301,
+		"words_result_num": 1,
+		"words_result": [{
+			"type": "bus_ticket",
+			"result": {
+				"invoice_code": [{"word": "144001900111"}],
+				"invoice_num": [{"word": "01234567"}],
+				"date": [{"word": "2024-04-02"}],
+				"time": [{"word": "14:30"}],
+				"starting_station": [{"word": "广州天河客运站"}],
+				"destination_station": [{"word": "东莞"}],
+				"amount": [{"word": "￥45.00"}],
+				"name": [{"word": "王五"}],
+				"id_num": [{"word": "440101199001011234"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, doctype.DocumentType(doctype.TypeBusTicket), d.DocumentType())
+	assert.Equal(t, "汽车票", d.SubTypeName())
+	assert.Equal(t, "2024.04.02", d.Date)
+	assert.Equal(t, "45.00", d.Amount)
+	assert.Equal(t, "144001900111-01234567", d.DocumentKey())
+
+	// 公路客票按 3% 计算抵扣
+	transport, ok := d.TransportDeduction()
+	require.True(t, ok)
+	assert.Equal(t, tax.CategoryRoadWater, transport.Category)
+	assert.Equal(t, 45.0, transport.Base)
+	assert.Equal(t, 1.31, transport.Deductible())
+	assert.Empty(t, transport.Remark)
+}
+
+func TestFerryTicketWithoutPassenger(t *testing.T) {
+	response := `{"words_result_num": 1, "words_result": [{"type": "ferry_ticket", "result": {
+		"invoice_num": [{"word": "00112233"}], "date": [{"word": "2024年04月03日"}],
+		"starting_station": [{"word": "蛇口"}], "destination_station": [{"word": "珠海九洲港"}],
+		"fare": [{"word": "110.00元"}]}}]}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "船票", d.SubTypeName())
+	transport, ok := d.TransportDeduction()
+	require.True(t, ok)
+	assert.Equal(t, 110.0, transport.Base)
+	assert.Equal(t, "未识别到旅客身份信息", transport.Remark)
+
+	docs := &Docs{}
+	docs.Add(d)
+	record := docs.Sheets()[0].Record(0)
+	assert.Equal(t, "船票", record["sub_type"])
+	assert.Equal(t, "珠海九洲港", record["destination_station"])
+}
+
+func TestEnrichPassenger(t *testing.T) {
+	directory := employee.NewDirectory([]*employee.Employee{
+		{Name: "王五", ID: "E003", Department: "销售部", CostCenter: "CC-300"},
+	})
+	d := &Doc{DocType: doctype.TypeBusTicket, Name: "王五", Date: "2024.04.02", Amount: "45.00"}
+	employee.Enrich(directory, []doctype.Document{d})
+
+	docs := &Docs{}
+	docs.Add(d)
+	record := docs.Sheets()[0].Record(0)
+	assert.Equal(t, "E003", record["employee_id"])
+	assert.Equal(t, "销售部", record["department"])
+	assert.Equal(t, "CC-300", record["cost_center"])
+}
@@ -0,0 +1,128 @@
+package train
+
+import (
+	"FinDocOCR/doctype"
+	"github.com/tidwall/gjson"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 票据类别
+const (
+	KindNormal    = "正常"
+	KindRefundFee = "退票费"
+	KindChanged   = "改签"
+)
+
+// 原车票在关联退票或改签后的状态
+const (
+	StatusRefunded = "已退票"
+	StatusChanged  = "已改签"
+)
+
+var (
+	originalTicketPattern = regexp.MustCompile(`原票号[^A-Za-z0-9]{0,4}([A-Za-z0-9]+)`)
+	refundFeePattern      = regexp.MustCompile(`退票费[^0-9]{0,6}([0-9]+(?:\.[0-9]+)?)`)
+)
+
+// kindFields 票面上标注退票、改签的字段。只检查这些字段，
+// 避免站名、乘车人等其他字段中出现的文字被误认为退票或改签
+var kindFields = []string{"remarks", "remark", "ticket_type", "title"}
+
+// kindText 返回标注退票、改签的字段的全部文字
+func kindText(wordsResult gjson.Result) string {
+	words := make([]string, 0)
+	for _, key := range kindFields {
+		for _, word := range wordsResult.Get(key).Array() {
+			words = append(words, word.Get("word").String())
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// detectKind 根据票面标注判断是否为退票费凭证或改签车票。
+// 退票费凭证的票价字段为原车票的票价，退票费只能从标注中解析，解析失败时票价留空并提示复核，
+// 避免将原票价计入费用
+func (d *Doc) detectKind(text string) {
+	switch {
+	case strings.Contains(text, "退票费"):
+		d.Kind = KindRefundFee
+		if match := refundFeePattern.FindStringSubmatch(text); match != nil {
+			d.TicketRates = match[1]
+		} else {
+			logger.Warnf("Refund fee of ticket %s not recognized", d.TicketNum)
+			d.TicketRates = ""
+			d.addReviewNote("退票费金额未识别，未计入费用")
+		}
+	case strings.Contains(text, "改签"), strings.Contains(text, "变更到站"):
+		d.Kind = KindChanged
+	default:
+		d.Kind = KindNormal
+	}
+
+	if match := originalTicketPattern.FindStringSubmatch(text); match != nil {
+		d.OriginalTicketNum = strings.ToUpper(match[1])
+	} else if d.Kind == KindRefundFee {
+		// 退票费凭证上的票号即为被退车票的票号。此时尚未执行 AmendData，需与 ticketKeys 一样统一为大写
+		d.OriginalTicketNum = strings.ToUpper(strings.TrimSpace(d.TicketNum))
+	}
+}
+
+// ticketKeys 返回用于关联的票号，纸质车票使用红色票号，电子客票使用电子客票号
+func (d *Doc) ticketKeys() []string {
+	keys := make([]string, 0, 2)
+	for _, key := range []string{d.TicketNum, d.ElecTicketNum} {
+		if key != "" {
+			keys = append(keys, strings.ToUpper(key))
+		}
+	}
+	return keys
+}
+
+// Reconcile 关联退票费凭证、改签车票与原车票，并计算每张票据的净额：
+// 正常车票为票价；退票费凭证为退票手续费；改签车票为新票价；
+// 被退票或改签的原车票净额为 0，避免重复计入费用
+func Reconcile(docs []doctype.Document) {
+	byTicket := make(map[string]*Doc)
+	tickets := make([]*Doc, 0, len(docs))
+	for _, doc := range docs {
+		d, ok := doc.(*Doc)
+		if !ok {
+			continue
+		}
+		tickets = append(tickets, d)
+		d.NetCost = d.TicketRates
+		if d.Kind == KindRefundFee {
+			continue
+		}
+		for _, key := range d.ticketKeys() {
+			byTicket[key] = d
+		}
+	}
+
+	for _, d := range tickets {
+		if d.Kind == KindNormal || d.OriginalTicketNum == "" {
+			continue
+		}
+		original, ok := byTicket[d.OriginalTicketNum]
+		if !ok || original == d {
+			logger.Warnf("Original ticket %s of %s ticket not found", d.OriginalTicketNum, d.Kind)
+			continue
+		}
+
+		original.NetCost = "0"
+		if d.Kind == KindRefundFee {
+			original.Status = StatusRefunded
+		} else {
+			original.Status = StatusChanged
+		}
+		logger.Infof("Linked %s ticket to original ticket %s", d.Kind, d.OriginalTicketNum)
+	}
+}
+
+// netCost 返回净额的数值
+func (d *Doc) netCost() float64 {
+	cost, _ := strconv.ParseFloat(d.NetCost, 64)
+	return cost
+}
@@ -0,0 +1,206 @@
+package train
+
+import (
+	"FinDocOCR/compliance"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 抵达日期来源
+const (
+	ArrivalFromTicket   = "票面"
+	ArrivalFromSchedule = "时刻表"
+	ArrivalEstimated    = "估算"
+)
+
+// ScheduleSource 提供车次在两站之间的运行时长，用于推算抵达日期
+type ScheduleSource interface {
+	Duration(trainNum, from, to string) (time.Duration, bool)
+}
+
+var (
+	scheduleMu sync.RWMutex
+	schedule   ScheduleSource
+)
+
+// SetScheduleSource 设置推算抵达日期使用的时刻表，传入 nil 时只使用规则估算。
+// 只有时刻表推算出的抵达时间可以计算运行时长
+func SetScheduleSource(s ScheduleSource) {
+	scheduleMu.Lock()
+	defer scheduleMu.Unlock()
+	schedule = s
+	if s != nil {
+		compliance.Provide(compliance.FieldDurationHours)
+	}
+}
+
+func lookupDuration(trainNum, from, to string) (time.Duration, bool) {
+	scheduleMu.RLock()
+	defer scheduleMu.RUnlock()
+	if schedule == nil {
+		return 0, false
+	}
+	return schedule.Duration(trainNum, from, to)
+}
+
+// CSVSchedule 从本地 CSV 文件读取的时刻表。
+// 文件需包含表头 train_num,from,to,duration，其中 from/to 可留空表示该车次的全程时长，
+// duration 支持"13:45"、分钟数或"13h45m"三种写法。
+type CSVSchedule struct {
+	durations map[string]time.Duration
+}
+
+func scheduleKey(trainNum, from, to string) string {
+	return strings.ToUpper(trainNum) + "|" + trimStation(from) + "|" + trimStation(to)
+}
+
+// trimStation 去掉站名末尾的"站"字，使"北京南站"与"北京南"一致
+func trimStation(name string) string {
+	return strings.TrimSuffix(strings.TrimSpace(name), "站")
+}
+
+// LoadCSVSchedule 读取 CSV 格式的时刻表
+func LoadCSVSchedule(path string) (*CSVSchedule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schedule: %w", err)
+	}
+	defer file.Close()
+
+	return ParseCSVSchedule(file)
+}
+
+// ParseCSVSchedule 解析 CSV 格式的时刻表
+func ParseCSVSchedule(r io.Reader) (*CSVSchedule, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedule header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+	for _, name := range []string{"train_num", "from", "to", "duration"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("schedule is missing column %s", name)
+		}
+	}
+
+	s := &CSVSchedule{durations: make(map[string]time.Duration)}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schedule line %d: %w", line, err)
+		}
+
+		duration, err := parseDuration(record[columns["duration"]])
+		if err != nil {
+			return nil, fmt.Errorf("schedule line %d: %w", line, err)
+		}
+		key := scheduleKey(record[columns["train_num"]], record[columns["from"]], record[columns["to"]])
+		s.durations[key] = duration
+	}
+	return s, nil
+}
+
+// parseDuration 解析"13:45"、分钟数或 Go 风格的时长
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if hours, minutes, ok := strings.Cut(s, ":"); ok {
+		h, errH := strconv.Atoi(hours)
+		m, errM := strconv.Atoi(minutes)
+		if errH != nil || errM != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+	}
+	if minutes, err := strconv.Atoi(s); err == nil {
+		return time.Duration(minutes) * time.Minute, nil
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return duration, nil
+}
+
+// Duration 优先匹配区间时长，其次匹配车次全程时长
+func (s *CSVSchedule) Duration(trainNum, from, to string) (time.Duration, bool) {
+	if d, ok := s.durations[scheduleKey(trainNum, from, to)]; ok {
+		return d, true
+	}
+	d, ok := s.durations[scheduleKey(trainNum, "", "")]
+	return d, ok
+}
+
+// isSleeper 判断是否为卧铺（含动卧）
+func (d *Doc) isSleeper() bool {
+	return strings.Contains(d.RawSeatCategory, "卧") || strings.Contains(d.SeatCategory, "卧")
+}
+
+// estimateOvernight 在没有时刻表数据时按规则估算是否次日抵达：
+// 卧铺在 18 点后发车（或发车时间未知）视为夜车；普速列车 21 点后发车同样视为次日抵达；
+// 其余情况视为当日抵达。
+func (d *Doc) estimateOvernight(departure time.Time, hasTime bool) bool {
+	if d.isSleeper() {
+		return !hasTime || departure.Hour() >= 18
+	}
+	if hasTime && d.TransportClass == ClassTrain {
+		return departure.Hour() >= 21
+	}
+	return false
+}
+
+// amendArrival 推算抵达日期与时间：票面已有日期时直接使用，
+// 否则根据发车时间与时刻表计算，缺少数据时按规则估算并标记为估算值
+func (d *Doc) amendArrival() {
+	if d.ArrivalDate != "" {
+		if d.ArrivalSource == "" {
+			d.ArrivalSource = ArrivalFromTicket
+		}
+		return
+	}
+
+	// 出发日期无法解析时只能沿用出发日期
+	departure, err := time.Parse("2006.01.02", d.StartDate)
+	if err != nil {
+		d.ArrivalDate = d.StartDate
+		d.ArrivalSource = ArrivalEstimated
+		return
+	}
+
+	hasTime := false
+	if clock, err := time.Parse("15:04", d.StartTime); err == nil {
+		departure = departure.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+		hasTime = true
+	}
+
+	if hasTime {
+		if duration, ok := lookupDuration(d.TrainNum, d.StartingStation, d.DestinationStation); ok {
+			arrival := departure.Add(duration)
+			d.ArrivalDate = arrival.Format("2006.01.02")
+			d.ArrivalTime = arrival.Format("15:04")
+			d.ArrivalSource = ArrivalFromSchedule
+			return
+		}
+	}
+
+	arrival := departure
+	if d.estimateOvernight(departure, hasTime) {
+		arrival = arrival.AddDate(0, 0, 1)
+	}
+	d.ArrivalDate = arrival.Format("2006.01.02")
+	d.ArrivalSource = ArrivalEstimated
+}
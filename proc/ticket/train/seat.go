@@ -0,0 +1,109 @@
+package train
+
+import (
+	_ "embed"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 交通类别
+const (
+	ClassTrain = "火车"
+	ClassEMU   = "动车"
+)
+
+//go:embed seat_categories.yaml
+var defaultSeatCategories []byte
+
+// SeatMapping 原始座席类别对应的报表标签与交通类别
+type SeatMapping struct {
+	Label string `yaml:"label"`
+	Class string `yaml:"class"`
+}
+
+type seatTableFile struct {
+	Seats map[string]SeatMapping `yaml:"seats"`
+}
+
+var (
+	seatTableMu sync.RWMutex
+	seatTable   map[string]SeatMapping
+)
+
+func init() {
+	table, err := parseSeatTable(defaultSeatCategories)
+	if err != nil {
+		panic(fmt.Sprintf("train: invalid embedded seat categories: %v", err))
+	}
+	seatTable = table
+}
+
+func parseSeatTable(content []byte) (map[string]SeatMapping, error) {
+	var file seatTableFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	table := make(map[string]SeatMapping, len(file.Seats))
+	for raw, mapping := range file.Seats {
+		if mapping.Label == "" {
+			return nil, fmt.Errorf("seat category %s has no label", raw)
+		}
+		table[strings.TrimSpace(raw)] = mapping
+	}
+	return table, nil
+}
+
+// LoadSeatCategories 读取用户的座席映射文件，其中的条目覆盖或补充内置映射
+func LoadSeatCategories(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read seat categories: %w", err)
+	}
+	overrides, err := parseSeatTable(content)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	seatTableMu.Lock()
+	defer seatTableMu.Unlock()
+	for raw, mapping := range overrides {
+		seatTable[raw] = mapping
+	}
+	return nil
+}
+
+// lookupSeat 查找原始座席类别的映射。交通类别为空时根据车次推断，
+// 并将标签补全为与其他条目一致的形式，如"无座"在动车上为"动车（无座）"
+func lookupSeat(raw, trainNum string) (SeatMapping, bool) {
+	seatTableMu.RLock()
+	mapping, ok := seatTable[strings.TrimSpace(raw)]
+	seatTableMu.RUnlock()
+
+	if ok && mapping.Class == "" {
+		mapping.Class = classOfTrain(trainNum)
+		mapping.Label = classLabel(mapping.Class, mapping.Label)
+	}
+	return mapping, ok
+}
+
+// classLabel 按交通类别生成报表标签，沿用映射表中火车用半角括号、动车用全角括号的写法
+func classLabel(class, seat string) string {
+	if class == ClassEMU {
+		return class + "（" + seat + "）"
+	}
+	return class + "(" + seat + ")"
+}
+
+// classOfTrain 根据车次字头判断交通类别
+func classOfTrain(trainNum string) string {
+	switch {
+	case strings.HasPrefix(trainNum, "G"), strings.HasPrefix(trainNum, "D"), strings.HasPrefix(trainNum, "C"):
+		return ClassEMU
+	default:
+		return ClassTrain
+	}
+}
@@ -0,0 +1,222 @@
+package station
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// embeddedStations 内置字典只收录主要客运站，作为种子数据；
+// 完整的车站表需通过 LoadDictionary 加载
+//
+//go:embed stations.csv
+var embeddedStations []byte
+
+// Station 车站信息
+type Station struct {
+	Name          string
+	TelegraphCode string
+	Pinyin        string
+	City          string
+	Province      string
+}
+
+var (
+	mu       sync.RWMutex
+	byName   = make(map[string]Station)
+	byPinyin = make(map[string]Station)
+	stations []Station
+)
+
+func init() {
+	if err := load(bytes.NewReader(embeddedStations)); err != nil {
+		panic(fmt.Sprintf("station: invalid embedded dictionary: %v", err))
+	}
+}
+
+// LoadFile 读取与内置字典格式相同的 CSV 文件（name,telegraph_code,pinyin,city,province），
+// 补充或覆盖内置字典中的车站
+func LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open station dictionary: %w", err)
+	}
+	defer file.Close()
+
+	if err := load(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadDictionary 以完整的车站表替换内置字典，文件格式与内置字典相同。
+// 加载失败时保留原有字典
+func LoadDictionary(path string) error {
+	mu.Lock()
+	savedByName, savedByPinyin, savedStations := byName, byPinyin, stations
+	byName = make(map[string]Station)
+	byPinyin = make(map[string]Station)
+	stations = nil
+	mu.Unlock()
+
+	if err := LoadFile(path); err != nil {
+		mu.Lock()
+		byName, byPinyin, stations = savedByName, savedByPinyin, savedStations
+		mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func load(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("empty station dictionary")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 跳过表头
+	for _, record := range records[1:] {
+		if len(record) < 5 {
+			return fmt.Errorf("invalid station record %v", record)
+		}
+		s := Station{
+			Name:          trim(record[0]),
+			TelegraphCode: strings.ToUpper(strings.TrimSpace(record[1])),
+			Pinyin:        strings.ToLower(strings.TrimSpace(record[2])),
+			City:          strings.TrimSpace(record[3]),
+			Province:      strings.TrimSpace(record[4]),
+		}
+		if _, exists := byName[s.Name]; !exists {
+			stations = append(stations, s)
+		} else {
+			for i := range stations {
+				if stations[i].Name == s.Name {
+					stations[i] = s
+				}
+			}
+		}
+		byName[s.Name] = s
+		if s.Pinyin != "" {
+			byPinyin[s.Pinyin] = s
+		}
+	}
+	return nil
+}
+
+// trim 去掉空白及末尾的"站"字，使"北京南站"与"北京南"一致
+func trim(name string) string {
+	name = strings.Join(strings.Fields(name), "")
+	return strings.TrimSuffix(name, "站")
+}
+
+// Lookup 按站名、拼音或电报码精确查找车站
+func Lookup(name string) (Station, bool) {
+	name = trim(name)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if s, ok := byName[name]; ok {
+		return s, true
+	}
+	if s, ok := byPinyin[strings.ToLower(name)]; ok {
+		return s, true
+	}
+	code := strings.ToUpper(name)
+	for _, s := range stations {
+		if s.TelegraphCode == code {
+			return s, true
+		}
+	}
+	return Station{}, false
+}
+
+// City 返回地名所在的城市：字典中的车站返回其城市，否则返回字典中作为前缀出现的最长城市名，
+// 用于机场（如"上海浦东"、"广州白云"）等不在字典中的站点。找不到时返回空字符串
+func City(name string) string {
+	if s, ok := Lookup(name); ok {
+		return s.City
+	}
+	name = trim(name)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	city := ""
+	for _, s := range stations {
+		if len(s.City) > len(city) && strings.HasPrefix(name, s.City) {
+			city = s.City
+		}
+	}
+	return city
+}
+
+// Suggest 精确查找失败时，按编辑距离给出最接近的车站，用于提示可能的 OCR 误识别。
+// 字典只收录主要客运站，相差一个字的往往是另一个真实的车站（如"昆山南"与"昆明南"），
+// 因此结果只能作为建议，不能直接替换识别结果。
+// 只有唯一一个编辑距离最小且不超过容差的车站时才给出建议。
+func Suggest(name string) (Station, bool) {
+	if _, ok := Lookup(name); ok {
+		return Station{}, false
+	}
+
+	target := []rune(trim(name))
+	if len(target) < 2 {
+		return Station{}, false
+	}
+	// 两三个字的站名只容忍一个字的差异，更长的站名容忍两个字
+	tolerance := 1
+	if len(target) >= 5 {
+		tolerance = 2
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	best, bestDistance, ambiguous := Station{}, tolerance+1, false
+	for _, s := range stations {
+		distance := levenshtein(target, []rune(s.Name))
+		switch {
+		case distance < bestDistance:
+			best, bestDistance, ambiguous = s, distance, false
+		case distance == bestDistance:
+			ambiguous = true
+		}
+	}
+	if bestDistance > tolerance || ambiguous {
+		return Station{}, false
+	}
+	return best, true
+}
+
+// levenshtein 计算两个字符串的编辑距离
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,82 @@
+package station
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	s, ok := Lookup("北京南站")
+	assert.True(t, ok)
+	assert.Equal(t, "北京南", s.Name)
+	assert.Equal(t, "北京", s.City)
+
+	s, ok = Lookup("AOH")
+	assert.True(t, ok)
+	assert.Equal(t, "上海虹桥", s.Name)
+
+	s, ok = Lookup("hankou")
+	assert.True(t, ok)
+	assert.Equal(t, "武汉", s.City)
+}
+
+func TestSuggest(t *testing.T) {
+	// OCR 将"虹"误识别为"红"
+	s, ok := Suggest("上海红桥")
+	assert.True(t, ok)
+	assert.Equal(t, "上海虹桥", s.Name)
+
+	// 字典中已有的车站不需要建议
+	_, ok = Suggest("深圳北站")
+	assert.False(t, ok)
+
+	// 与多个车站距离相同时不给出建议
+	_, ok = Suggest("北京中")
+	assert.False(t, ok)
+
+	_, ok = Suggest("不存在的站")
+	assert.False(t, ok)
+}
+
+func TestCity(t *testing.T) {
+	assert.Equal(t, "上海", City("上海虹桥"))
+	assert.Equal(t, "广州", City("广州白云"))
+	assert.Equal(t, "", City("不存在"))
+}
+
+func TestLoadDictionary(t *testing.T) {
+	t.Cleanup(func() {
+		byName = make(map[string]Station)
+		byPinyin = make(map[string]Station)
+		stations = nil
+		require.NoError(t, load(bytes.NewReader(embeddedStations)))
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stations.csv")
+	content := "name,telegraph_code,pinyin,city,province\n" +
+		"昆山南,KNH,kunshannan,苏州,江苏\n" +
+		"昆明南,KOM,kunmingnan,昆明,云南\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	// 加载失败时保留原有字典
+	assert.Error(t, LoadDictionary(filepath.Join(dir, "missing.csv")))
+	_, ok := Lookup("北京南")
+	assert.True(t, ok)
+
+	// 完整的车站表替换内置字典
+	require.NoError(t, LoadDictionary(path))
+	s, ok := Lookup("昆山南站")
+	require.True(t, ok)
+	assert.Equal(t, "苏州", s.City)
+	_, ok = Lookup("北京南")
+	assert.False(t, ok)
+	// 字典中已有的真实车站不再被当作误识别
+	_, ok = Suggest("昆山南")
+	assert.False(t, ok)
+}
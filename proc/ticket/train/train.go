@@ -1,67 +1,306 @@
 package train
 
 import (
+	"FinDocOCR/compliance"
 	"FinDocOCR/config"
 	"FinDocOCR/doctype"
+	"FinDocOCR/employee"
+	"FinDocOCR/proc/field"
+	"FinDocOCR/proc/ticket/train/station"
+	"FinDocOCR/tax"
+	"FinDocOCR/travel"
 	"fmt"
 	"github.com/tidwall/gjson"
-	"github.com/xuri/excelize/v2"
-	"log"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var logger = config.GetLogger()
 
+// output 导出文件名
+const output = "火车票处理结果"
+
+func init() {
+	doctype.Register(doctype.Registration{
+		Type:          doctype.TypeTrainTicket,
+		Name:          "火车票",
+		Processor:     &Processor{},
+		NewCollection: func() doctype.DocumentCollection { return &Docs{} },
+		Output:        output,
+		Reconcile:     Reconcile,
+	})
+	// 运行时长依赖时刻表，在设置时刻表后声明
+	compliance.Provide(compliance.FieldPassenger, compliance.FieldDate, compliance.FieldNumber,
+		compliance.FieldSeatCategory, compliance.FieldRawSeat, compliance.FieldTransportClass,
+		compliance.FieldFare, compliance.FieldGrade, compliance.FieldDepartment)
+}
+
+// 车票版式
+const (
+	LayoutPaper      = "纸质车票"
+	LayoutElectronic = "铁路电子客票"
+)
+
+var (
+	datePattern = regexp.MustCompile(`\d{4}年\d{1,2}月\d{1,2}日`)
+	timePattern = regexp.MustCompile(`(\d{1,2}):(\d{2})`)
+)
+
 // Doc represents train ticket data
 type Doc struct {
-	Name               string
-	StartDate          string
-	StartingStation    string
-	ArrivalDate        string
+	Name            string
+	StartDate       string
+	StartTime       string
+	StartingStation string
+	ArrivalDate     string
+	ArrivalTime     string
+	// ArrivalSource 抵达日期的来源：票面、时刻表或估算
+	ArrivalSource      string
 	DestinationStation string
-	SeatCategory       string
-	TicketRates        string
+	OriginCity         string
+	DestinationCity    string
+	// ReviewNote 需要人工复核的问题，如站名不在车站字典中、退票费金额未识别
+	ReviewNote string
+	TrainNum   string
+	// SeatCategory 为映射后的报表标签，RawSeatCategory 保留识别出的原文
+	SeatCategory    string
+	RawSeatCategory string
+	TransportClass  string
+	SeatNum         string
+	TicketRates     string
+	TicketNum       string
+	IDNum           string
+	// ElecTicketNum 与 InvoiceNum 只出现在铁路电子客票上
+	ElecTicketNum string
+	InvoiceNum    string
+	Layout        string
+	// Kind 票据类别：正常、退票费或改签
+	Kind              string
+	OriginalTicketNum string
+	// Status 被退票或改签的原车票的状态
+	Status string
+	// NetCost 扣除退票、改签后实际计入的费用
+	NetCost string
+	// 乘车人关联的员工信息
+	employee.Assignment
+	// 差旅合规检查结果
+	compliance.Flags
+	doctype.DuplicateMark
+	doctype.Source
 }
 
 func (d *Doc) String() string {
-	return fmt.Sprintf("Name: %s, StartDate: %s, StartingStation: %s, ArrivalDate: %s, DestinationStation: %s, SeatCategory: %s, TicketRates: %s",
-		d.Name, d.StartDate, d.StartingStation, d.ArrivalDate, d.DestinationStation, d.SeatCategory, d.TicketRates)
+	return fmt.Sprintf("Name: %s, StartDate: %s, StartTime: %s, StartingStation: %s, ArrivalDate: %s, ArrivalSource: %s, DestinationStation: %s, "+
+		"TrainNum: %s, SeatCategory: %s, RawSeatCategory: %s, TransportClass: %s, SeatNum: %s, TicketRates: %s, TicketNum: %s, IDNum: %s, ElecTicketNum: %s, InvoiceNum: %s, Layout: %s, "+
+		"Kind: %s, OriginalTicketNum: %s, Status: %s, NetCost: %s",
+		d.Name, d.StartDate, d.StartTime, d.StartingStation, d.ArrivalDate, d.ArrivalSource, d.DestinationStation,
+		d.TrainNum, d.SeatCategory, d.RawSeatCategory, d.TransportClass, d.SeatNum, d.TicketRates, d.TicketNum, d.IDNum, d.ElecTicketNum, d.InvoiceNum, d.Layout,
+		d.Kind, d.OriginalTicketNum, d.Status, d.NetCost)
+}
+
+func (d *Doc) DocumentType() doctype.DocumentType {
+	return doctype.TypeTrainTicket
+}
+
+// PersonName 返回用于匹配员工的乘车人姓名
+func (d *Doc) PersonName() string {
+	return d.Name
 }
 
 func (d *Doc) AmendData() {
+	// 乘车日期中可能带有发车时间，如"2024年01月05日08:35开"
+	if d.StartTime == "" {
+		d.StartTime = d.StartDate
+	}
+	if date := datePattern.FindString(d.StartDate); date != "" {
+		d.StartDate = date
+	}
+	d.StartTime = normalizeTime(d.StartTime)
+
 	// 替换日期格式
-	d.StartDate = strings.ReplaceAll(d.StartDate, "年", ".")
-	d.StartDate = strings.ReplaceAll(d.StartDate, "月", ".")
-	d.StartDate = strings.ReplaceAll(d.StartDate, "日", "")
+	d.StartDate = field.NormalizeDate(d.StartDate)
 
-	// 更新座位类别
-	if d.SeatCategory == "新空调硬卧" {
-		d.SeatCategory = "火车(硬卧)"
-	} else if d.SeatCategory == "二等座" {
-		d.SeatCategory = "动车（二等座）"
-	}
+	// 车次与票号统一为大写
+	d.TrainNum = strings.ToUpper(strings.TrimSpace(d.TrainNum))
+	d.TicketNum = strings.ToUpper(strings.TrimSpace(d.TicketNum))
 
-	if d.ArrivalDate != "" {
-		d.ArrivalDate = strings.ReplaceAll(d.ArrivalDate, "年", ".")
-		d.ArrivalDate = strings.ReplaceAll(d.ArrivalDate, "月", ".")
-		d.ArrivalDate = strings.ReplaceAll(d.ArrivalDate, "日", "")
+	// 按映射表更新座位类别
+	if d.RawSeatCategory == "" {
+		d.RawSeatCategory = d.SeatCategory
+	}
+	if mapping, ok := lookupSeat(d.RawSeatCategory, d.TrainNum); ok {
+		d.SeatCategory = mapping.Label
+		d.TransportClass = mapping.Class
 	} else {
-		// 如果没有抵达日期，默认设置为出发日期
-		d.ArrivalDate = d.StartDate
-		if d.SeatCategory == "火车(硬卧)" {
-			// 抵达日期为之后一天
-			startDateTime, err := time.Parse("2006.01.02", d.StartDate)
-			if err == nil {
-				arrivalDateTime := startDateTime.AddDate(0, 0, 1)
-				d.ArrivalDate = arrivalDateTime.Format("2006.01.02")
-			}
+		logger.Warnf("Unmapped seat category %q of train %s", d.RawSeatCategory, d.TrainNum)
+		d.SeatCategory = d.RawSeatCategory
+		d.TransportClass = classOfTrain(d.TrainNum)
+		if d.RawSeatCategory != "" {
+			d.addReviewNote(fmt.Sprintf("座席类别\"%s\"未收录", d.RawSeatCategory))
 		}
 	}
 
+	// 按车站字典统一站名并补充所在城市，字典中没有的站名保留原文并提示复核
+	var note string
+	d.StartingStation, d.OriginCity, note = normalizeStation(d.StartingStation)
+	if note != "" {
+		d.addReviewNote("起始地" + note)
+	}
+	d.DestinationStation, d.DestinationCity, note = normalizeStation(d.DestinationStation)
+	if note != "" {
+		d.addReviewNote("目的地" + note)
+	}
+
+	d.ArrivalDate = field.NormalizeDate(d.ArrivalDate)
+	d.amendArrival()
+
 	// 去除票价中的符号
 	d.TicketRates = strings.ReplaceAll(d.TicketRates, "￥", "")
 	d.TicketRates = strings.ReplaceAll(d.TicketRates, "元", "")
+
+	if d.ElecTicketNum != "" || d.InvoiceNum != "" {
+		d.Layout = LayoutElectronic
+	} else {
+		d.Layout = LayoutPaper
+	}
+
+	if d.Kind == "" {
+		d.Kind = KindNormal
+	}
+	if d.NetCost == "" {
+		d.NetCost = d.TicketRates
+	}
+}
+
+// TravelLeg 将车票转换为行程段。退票费凭证与已退票、已改签的原车票不构成行程，
+// 出发日期无法解析时同样返回 false
+func (d *Doc) TravelLeg() (travel.Leg, bool) {
+	if d.Kind == KindRefundFee || d.Status != "" {
+		return travel.Leg{}, false
+	}
+
+	departure, err := time.Parse("2006.01.02 15:04", d.StartDate+" "+d.StartTime)
+	if err != nil {
+		departure, err = time.Parse("2006.01.02", d.StartDate)
+		if err != nil {
+			return travel.Leg{}, false
+		}
+	}
+	arrival, err := time.Parse("2006.01.02", d.ArrivalDate)
+	if err != nil {
+		arrival = departure
+	}
+
+	return travel.Leg{
+		Passenger:       d.Name,
+		Mode:            travel.ModeTrain,
+		Number:          d.TrainNum,
+		Departure:       departure,
+		Arrival:         arrival,
+		Origin:          d.StartingStation,
+		Destination:     d.DestinationStation,
+		OriginCity:      d.OriginCity,
+		DestinationCity: d.DestinationCity,
+		Fare:            d.netCost(),
+	}, true
+}
+
+// TransportDeduction 返回计算进项税抵扣所需的信息。退票费凭证不属于旅客运输服务，
+// 已退票、已改签的原车票不再计入，改签车票按新票价计算
+func (d *Doc) TransportDeduction() (tax.Transport, bool) {
+	if d.Kind == KindRefundFee || d.Status != "" {
+		return tax.Transport{}, false
+	}
+	date, err := time.Parse("2006.01.02", d.StartDate)
+	if err != nil {
+		return tax.Transport{}, false
+	}
+
+	number := d.TicketNum
+	if d.ElecTicketNum != "" {
+		number = d.ElecTicketNum
+	}
+	remark := ""
+	if d.IDNum == "" {
+		remark = "未识别到旅客身份信息"
+	}
+
+	return tax.Transport{
+		Category:  tax.CategoryRailway,
+		Date:      date,
+		Passenger: d.Name,
+		Number:    number,
+		Base:      d.netCost(),
+		Remark:    remark,
+	}, true
+}
+
+// DocumentKey 以票据类别与票号标识一张车票，退票费凭证与被退车票的票号相同，需要以类别区分
+func (d *Doc) DocumentKey() string {
+	keys := d.ticketKeys()
+	if len(keys) == 0 {
+		return ""
+	}
+	return d.Kind + "|" + strings.Join(keys, "|")
+}
+
+// Facts 返回合规检查使用的字段，运行时长只在抵达时间已知时提供
+func (d *Doc) Facts() compliance.Facts {
+	facts := compliance.Facts{
+		compliance.FieldPassenger:      d.Name,
+		compliance.FieldDate:           d.StartDate,
+		compliance.FieldNumber:         d.TrainNum,
+		compliance.FieldSeatCategory:   d.SeatCategory,
+		compliance.FieldRawSeat:        d.RawSeatCategory,
+		compliance.FieldTransportClass: d.TransportClass,
+		compliance.FieldFare:           d.NetCost,
+		compliance.FieldGrade:          d.Grade,
+		compliance.FieldDepartment:     d.Department,
+	}
+
+	departure, errDeparture := time.Parse("2006.01.02 15:04", d.StartDate+" "+d.StartTime)
+	arrival, errArrival := time.Parse("2006.01.02 15:04", d.ArrivalDate+" "+d.ArrivalTime)
+	if errDeparture == nil && errArrival == nil {
+		facts[compliance.FieldDurationHours] = strconv.FormatFloat(arrival.Sub(departure).Hours(), 'f', 2, 64)
+	}
+	return facts
+}
+
+// addReviewNote 追加需要人工复核的问题
+func (d *Doc) addReviewNote(note string) {
+	if d.ReviewNote != "" {
+		d.ReviewNote += "；"
+	}
+	d.ReviewNote += note
+}
+
+// normalizeStation 按车站字典统一站名，返回站名、所在城市与复核提示。
+// 字典中找不到时保留原站名，城市留空；与字典中的车站相近时在提示中给出建议，
+// 但不替换原站名，避免将字典未收录的真实车站改为另一个城市的车站
+func normalizeStation(name string) (string, string, string) {
+	if name == "" {
+		return "", "", ""
+	}
+	if s, ok := station.Lookup(name); ok {
+		return s.Name, s.City, ""
+	}
+	if s, ok := station.Suggest(name); ok {
+		logger.Warnf("Unknown station %q, did you mean %q", name, s.Name)
+		return name, "", fmt.Sprintf("%q未收录，疑为%q", name, s.Name)
+	}
+	logger.Warnf("Unknown station %q", name)
+	return name, "", ""
+}
+
+// normalizeTime 从"8:35开"等文字中提取发车时间，统一为"08:35"
+func normalizeTime(s string) string {
+	match := timePattern.FindStringSubmatch(s)
+	if match == nil {
+		return ""
+	}
+	return fmt.Sprintf("%02s:%s", match[1], match[2])
 }
 
 type Processor struct{}
@@ -74,14 +313,22 @@ func (p *Processor) Process(data []byte) (doctype.Document, error) {
 		return &d, fmt.Errorf("no words_result in response, error_code: %s", errCode)
 	}
 
-	// 修改解析路径，获取数组中的 word 字段
-	d.Name = wordsResult.Get("name.0.word").String()
-	d.StartDate = wordsResult.Get("date.0.word").String()
-	d.StartingStation = wordsResult.Get("starting_station.0.word").String()
-	d.DestinationStation = wordsResult.Get("destination_station.0.word").String()
-	d.SeatCategory = wordsResult.Get("seat_category.0.word").String()
-	d.TicketRates = wordsResult.Get("ticket_rates.0.word").String()
+	// 纸质车票与铁路电子客票的字段命名不完全相同，按顺序尝试
+	d.Name = field.FirstWord(wordsResult, "name", "passenger_name")
+	d.StartDate = field.FirstWord(wordsResult, "date", "travel_date")
+	d.StartTime = field.FirstWord(wordsResult, "time", "departure_time")
+	d.StartingStation = field.FirstWord(wordsResult, "starting_station")
+	d.DestinationStation = field.FirstWord(wordsResult, "destination_station")
+	d.TrainNum = field.FirstWord(wordsResult, "train_num")
+	d.SeatCategory = field.FirstWord(wordsResult, "seat_category")
+	d.SeatNum = field.FirstWord(wordsResult, "seat_num")
+	d.TicketRates = field.FirstWord(wordsResult, "ticket_rates", "fare")
+	d.TicketNum = field.FirstWord(wordsResult, "ticket_num", "serial_number")
+	d.IDNum = field.FirstWord(wordsResult, "ID_card", "ID_num", "id_num", "identity_card")
+	d.ElecTicketNum = field.FirstWord(wordsResult, "elec_ticket_num", "ElecTicketNum")
+	d.InvoiceNum = field.FirstWord(wordsResult, "invoice_num", "InvoiceNum")
 	d.ArrivalDate = ""
+	d.detectKind(kindText(wordsResult))
 	d.AmendData()
 
 	logger.Info("Train ticket data: ", d)
@@ -100,74 +347,84 @@ func (docs *Docs) Add(doc doctype.Document) {
 	*docs = append(*docs, *d)
 }
 
-func (docs *Docs) SaveToFile() error {
-	// 初始化 Excel 文件
-	f := excelize.NewFile()
-	defer func() {
-		if err := f.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
-
-	// 设置常量
-	const (
-		filename  = "火车票处理结果.xlsx"
-		sheetName = "Sheet1"
-	)
-
-	// 定义表头
-	headers := []string{
-		"*人员",
-		"*出发日期",
-		"*起始地",
-		"*抵达日期",
-		"*目的地",
-		"*交通工具",
-		"*票价",
-	}
-
-	// 写入表头
-	for i, header := range headers {
-		cell, err := excelize.CoordinatesToCellName(i+1, 1)
-		if err != nil {
-			return fmt.Errorf("failed to convert coordinates for header %d: %w", i+1, err)
-		}
-
-		if err := f.SetCellValue(sheetName, cell, header); err != nil {
-			return fmt.Errorf("failed to write header at cell %s: %w", cell, err)
-		}
-	}
+// columns 导出列，带"*"的列为财务系统导入格式要求的必填列
+var columns = []doctype.Column{
+	{Key: "name", Header: "*人员", Role: doctype.RolePerson},
+	{Key: "start_date", Header: "*出发日期", Type: doctype.ColumnDate, Role: doctype.RoleDate},
+	{Key: "starting_station", Header: "*起始地"},
+	{Key: "arrival_date", Header: "*抵达日期", Type: doctype.ColumnDate},
+	{Key: "destination_station", Header: "*目的地"},
+	{Key: "seat_category", Header: "*交通工具"},
+	{Key: "net_cost", Header: "*票价", Type: doctype.ColumnMoney, Role: doctype.RoleAmount},
+	{Key: "ticket_rates", Header: "票面金额", Type: doctype.ColumnMoney},
+	{Key: "train_num", Header: "车次"},
+	{Key: "start_time", Header: "发车时间"},
+	{Key: "seat_num", Header: "座位号"},
+	{Key: "ticket_num", Header: "车票号", Role: doctype.RoleNumber},
+	{Key: "id_num", Header: "身份证号"},
+	{Key: "elec_ticket_num", Header: "电子客票号"},
+	{Key: "invoice_num", Header: "发票号码"},
+	{Key: "layout", Header: "票面类型"},
+	{Key: "raw_seat_category", Header: "座席原文"},
+	{Key: "transport_class", Header: "交通类别"},
+	{Key: "arrival_time", Header: "抵达时间"},
+	{Key: "arrival_source", Header: "抵达日期来源"},
+	{Key: "origin_city", Header: "出发城市"},
+	{Key: "destination_city", Header: "到达城市"},
+	{Key: "review_note", Header: "待复核", Warning: true},
+	{Key: "kind", Header: "票据类别"},
+	{Key: "original_ticket_num", Header: "原票号"},
+	{Key: "status", Header: "状态", Warning: true},
+	{Key: "employee_id", Header: "工号"},
+	{Key: "department", Header: "部门"},
+	{Key: "cost_center", Header: "成本中心"},
+	{Key: "match_status", Header: "员工匹配", Warning: true},
+	{Key: "violations", Header: "合规检查", Warning: true},
+	{Key: "duplicate", Header: "重复", Warning: true},
+	{Key: "source", Header: "原始文件", Type: doctype.ColumnLink},
+	{Key: "thumbnail", Header: "缩略图", Type: doctype.ColumnImage},
+}
 
-	// 写入数据行
-	for rowNum, ticket := range *docs {
-		rowValues := []interface{}{
+func (docs *Docs) Sheets() []doctype.Sheet {
+	rows := make([][]interface{}, 0, len(*docs))
+	for _, ticket := range *docs {
+		rows = append(rows, []interface{}{
 			ticket.Name,
 			ticket.StartDate,
 			ticket.StartingStation,
 			ticket.ArrivalDate,
 			ticket.DestinationStation,
 			ticket.SeatCategory,
+			ticket.NetCost,
 			ticket.TicketRates,
-		}
-
-		// 写入每一列的数据
-		for colNum, value := range rowValues {
-			cell, err := excelize.CoordinatesToCellName(colNum+1, rowNum+2)
-			if err != nil {
-				return fmt.Errorf("failed to convert coordinates for row %d, column %d: %w",
-					rowNum+2, colNum+1, err)
-			}
-
-			if err := f.SetCellValue(sheetName, cell, value); err != nil {
-				return fmt.Errorf("failed to write value at cell %s: %w", cell, err)
-			}
-		}
-	}
-
-	// 保存文件
-	if err := f.SaveAs(filename); err != nil {
-		return fmt.Errorf("failed to save file %s: %w", filename, err)
+			ticket.TrainNum,
+			ticket.StartTime,
+			ticket.SeatNum,
+			ticket.TicketNum,
+			ticket.IDNum,
+			ticket.ElecTicketNum,
+			ticket.InvoiceNum,
+			ticket.Layout,
+			ticket.RawSeatCategory,
+			ticket.TransportClass,
+			ticket.ArrivalTime,
+			ticket.ArrivalSource,
+			ticket.OriginCity,
+			ticket.DestinationCity,
+			ticket.ReviewNote,
+			ticket.Kind,
+			ticket.OriginalTicketNum,
+			ticket.Status,
+			ticket.EmployeeID,
+			ticket.Department,
+			ticket.CostCenter,
+			ticket.MatchStatus,
+			ticket.ViolationSummary(),
+			ticket.DuplicateNote(),
+			ticket.SourcePath(),
+			ticket.Thumbnail(),
+		})
 	}
 
-	return nil
+	return []doctype.Sheet{{Name: "火车票", Columns: columns, Rows: rows, Keys: []string{"kind", "ticket_num", "elec_ticket_num"}}}
 }
@@ -0,0 +1,273 @@
+package train
+
+import (
+	"FinDocOCR/doctype"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessElectronicTicket(t *testing.T) {
+	response := `{
+		"words_result_num": 1,
+		"words_result": [{
+			"type": "train_ticket",
+			"result": {
+				"name": [{"word": "张三"}],
+				"date": [{"word": "2024年01月05日8:35开"}],
+				"starting_station": [{"word": "北京南"}],
+				"destination_station": [{"word": "上海虹桥"}],
+				"train_num": [{"word": "g1"}],
+				"seat_category": [{"word": "二等座"}],
+				"seat_num": [{"word": "05车12F号"}],
+				"ticket_rates": [{"word": "￥553.0元"}],
+				"ID_num": [{"word": "1101011990****1234"}],
+				"elec_ticket_num": [{"word": "E123456789"}],
+				"invoice_num": [{"word": "24119000000012345678"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "2024.01.05", d.StartDate)
+	assert.Equal(t, "08:35", d.StartTime)
+	assert.Equal(t, "G1", d.TrainNum)
+	assert.Equal(t, "553.0", d.TicketRates)
+	assert.Equal(t, "E123456789", d.ElecTicketNum)
+	assert.Equal(t, LayoutElectronic, d.Layout)
+}
+
+func TestProcessPaperTicket(t *testing.T) {
+	response := `{
+		"words_result_num": 1,
+		"words_result": [{
+			"type": "train_ticket",
+			"result": {
+				"name": [{"word": "李四"}],
+				"date": [{"word": "2023年12月01日"}],
+				"time": [{"word": "21:10开"}],
+				"train_num": [{"word": "K1234"}],
+				"ticket_num": [{"word": "z012345"}],
+				"seat_category": [{"word": "新空调硬卧"}]
+			}
+		}]
+	}`
+
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+
+	d := doc.(*Doc)
+	assert.Equal(t, "2023.12.01", d.StartDate)
+	assert.Equal(t, "21:10", d.StartTime)
+	assert.Equal(t, "Z012345", d.TicketNum)
+	assert.Equal(t, LayoutPaper, d.Layout)
+}
+
+func TestSeatCategoryMapping(t *testing.T) {
+	cases := []struct {
+		raw, trainNum, label, class string
+	}{
+		{"新空调硬卧", "K1234", "火车(硬卧)", ClassTrain},
+		{"二等座", "G1", "动车（二等座）", ClassEMU},
+		{"商务座", "G1", "动车（商务座）", ClassEMU},
+		{"无座", "D301", "动车（无座）", ClassEMU},
+		{"无座", "T110", "火车(无座)", ClassTrain},
+		// 未收录的类别保留原文
+		{"包厢", "Z1", "包厢", ClassTrain},
+	}
+
+	for _, c := range cases {
+		d := Doc{SeatCategory: c.raw, TrainNum: c.trainNum}
+		d.AmendData()
+		assert.Equal(t, c.label, d.SeatCategory, c.raw)
+		assert.Equal(t, c.class, d.TransportClass, c.raw)
+		assert.Equal(t, c.raw, d.RawSeatCategory)
+	}
+
+	// 未收录的类别提示复核
+	d := Doc{SeatCategory: "包厢", TrainNum: "Z1"}
+	d.AmendData()
+	assert.Contains(t, d.ReviewNote, `座席类别"包厢"未收录`)
+}
+
+func TestArrivalFromSchedule(t *testing.T) {
+	schedule, err := ParseCSVSchedule(strings.NewReader(
+		"train_num,from,to,duration\n" +
+			"Z1,北京西,武昌,10:30\n" +
+			"D701,,,690\n"))
+	require.NoError(t, err)
+	SetScheduleSource(schedule)
+	defer SetScheduleSource(nil)
+
+	d := Doc{StartDate: "2024年03月01日", StartTime: "20:15开", TrainNum: "Z1",
+		StartingStation: "北京西站", DestinationStation: "武昌", SeatCategory: "软卧"}
+	d.AmendData()
+	assert.Equal(t, "2024.03.02", d.ArrivalDate)
+	assert.Equal(t, "06:45", d.ArrivalTime)
+	assert.Equal(t, ArrivalFromSchedule, d.ArrivalSource)
+
+	// 动卧按车次全程时长推算
+	d = Doc{StartDate: "2024年03月01日", StartTime: "19:30", TrainNum: "D701", SeatCategory: "动卧"}
+	d.AmendData()
+	assert.Equal(t, "2024.03.02", d.ArrivalDate)
+	assert.Equal(t, ArrivalFromSchedule, d.ArrivalSource)
+}
+
+func TestArrivalEstimate(t *testing.T) {
+	cases := []struct {
+		name, seat, trainNum, startTime, arrival string
+	}{
+		{"night soft sleeper", "软卧", "T110", "21:00", "2024.03.02"},
+		{"day hard sleeper", "新空调硬卧", "K1234", "07:20", "2024.03.01"},
+		{"sleeper without time", "新空调硬卧", "K1234", "", "2024.03.02"},
+		{"late seat on normal train", "硬座", "K1234", "22:30", "2024.03.02"},
+		{"late high speed train", "二等座", "G1", "22:30", "2024.03.01"},
+	}
+
+	for _, c := range cases {
+		d := Doc{StartDate: "2024年03月01日", StartTime: c.startTime, TrainNum: c.trainNum, SeatCategory: c.seat}
+		d.AmendData()
+		assert.Equal(t, c.arrival, d.ArrivalDate, c.name)
+		assert.Equal(t, ArrivalEstimated, d.ArrivalSource, c.name)
+	}
+}
+
+func TestStationNormalization(t *testing.T) {
+	d := Doc{StartDate: "2024年03月01日", StartingStation: "上海虹桥站", DestinationStation: "汉口", SeatCategory: "二等座", TrainNum: "G1"}
+	d.AmendData()
+	assert.Equal(t, "上海虹桥", d.StartingStation)
+	assert.Equal(t, "上海", d.OriginCity)
+	assert.Equal(t, "汉口", d.DestinationStation)
+	assert.Equal(t, "武汉", d.DestinationCity)
+	assert.Empty(t, d.ReviewNote)
+
+	// 疑似误识别的站名只提示，不替换
+	d = Doc{StartDate: "2024年03月01日", StartingStation: "上海红桥", DestinationStation: "汉口", SeatCategory: "二等座", TrainNum: "G1"}
+	d.AmendData()
+	assert.Equal(t, "上海红桥", d.StartingStation)
+	assert.Empty(t, d.OriginCity)
+	assert.Contains(t, d.ReviewNote, "上海虹桥")
+}
+
+func TestStationNotInDictionary(t *testing.T) {
+	// 字典未收录的真实车站与字典中的车站只差一个字，不能改为另一个城市的车站
+	cases := []struct {
+		station    string
+		suggestion string
+	}{
+		{"昆山南", "昆明南"},
+		{"金华南", "金华"},
+		{"宁波东", "宁波"},
+	}
+
+	for _, c := range cases {
+		d := Doc{StartDate: "2024年03月01日", StartingStation: c.station, DestinationStation: "上海虹桥", SeatCategory: "二等座", TrainNum: "G7"}
+		d.AmendData()
+		assert.Equal(t, c.station, d.StartingStation, c.station)
+		assert.Empty(t, d.OriginCity, c.station)
+		assert.Equal(t, "上海", d.DestinationCity, c.station)
+		assert.Contains(t, d.ReviewNote, "起始地", c.station)
+		assert.Contains(t, d.ReviewNote, c.suggestion, c.station)
+	}
+}
+
+func TestReconcileRefundAndChange(t *testing.T) {
+	process := func(result string) *Doc {
+		response := `{"words_result_num": 1, "words_result": [{"type": "train_ticket", "result": ` + result + `}]}`
+		doc, err := (&Processor{}).Process([]byte(response))
+		require.NoError(t, err)
+		return doc.(*Doc)
+	}
+
+	original := process(`{"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": "A000001"}],
+		"ticket_rates": [{"word": "￥300.0元"}], "seat_category": [{"word": "二等座"}]}`)
+	refund := process(`{"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": "A000001"}],
+		"remarks": [{"word": "退票费：￥15.0元"}], "ticket_rates": [{"word": "￥300.0元"}]}`)
+	rebooked := process(`{"date": [{"word": "2024年03月02日"}], "ticket_num": [{"word": "B000002"}],
+		"remarks": [{"word": "始发改签 原票号：C000003"}], "ticket_rates": [{"word": "￥320.0元"}], "seat_category": [{"word": "二等座"}]}`)
+	changedOriginal := process(`{"date": [{"word": "2024年03月02日"}], "ticket_num": [{"word": "C000003"}],
+		"ticket_rates": [{"word": "￥280.0元"}], "seat_category": [{"word": "二等座"}]}`)
+
+	assert.Equal(t, KindNormal, original.Kind)
+	assert.Equal(t, KindRefundFee, refund.Kind)
+	assert.Equal(t, "A000001", refund.OriginalTicketNum)
+	assert.Equal(t, KindChanged, rebooked.Kind)
+	assert.Equal(t, "C000003", rebooked.OriginalTicketNum)
+
+	Reconcile([]doctype.Document{original, refund, rebooked, changedOriginal})
+
+	assert.Equal(t, StatusRefunded, original.Status)
+	assert.Equal(t, "0", original.NetCost)
+	assert.Equal(t, "15.0", refund.NetCost)
+	assert.Equal(t, "320.0", rebooked.NetCost)
+	assert.Equal(t, StatusChanged, changedOriginal.Status)
+	assert.Equal(t, "0", changedOriginal.NetCost)
+
+	// 只有改签后的车票构成行程
+	_, ok := original.TravelLeg()
+	assert.False(t, ok)
+	_, ok = refund.TravelLeg()
+	assert.False(t, ok)
+	leg, ok := rebooked.TravelLeg()
+	assert.True(t, ok)
+	assert.Equal(t, 320.0, leg.Fare)
+}
+
+func TestReconcileLowercaseRefundTicketNum(t *testing.T) {
+	// 识别出的票号为小写且带空格时，退票费凭证仍能关联到原车票
+	process := func(result string) *Doc {
+		response := `{"words_result_num": 1, "words_result": [{"type": "train_ticket", "result": ` + result + `}]}`
+		doc, err := (&Processor{}).Process([]byte(response))
+		require.NoError(t, err)
+		return doc.(*Doc)
+	}
+
+	original := process(`{"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": "E000021"}],
+		"ticket_rates": [{"word": "￥300.0元"}], "seat_category": [{"word": "二等座"}]}`)
+	refund := process(`{"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": " e000021 "}],
+		"remarks": [{"word": "退票费：￥15.0元"}], "ticket_rates": [{"word": "￥300.0元"}]}`)
+	assert.Equal(t, "E000021", refund.OriginalTicketNum)
+
+	Reconcile([]doctype.Document{original, refund})
+	assert.Equal(t, StatusRefunded, original.Status)
+	assert.Equal(t, "0", original.NetCost)
+}
+
+func TestDetectKindFromRemarksOnly(t *testing.T) {
+	// 纸质车票底部印有"退票改签时须交回车站"，不能据此判断为退票或改签
+	response := `{"words_result_num": 1, "words_result": [{"type": "train_ticket", "result": {
+		"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": "A000010"}],
+		"ticket_rates": [{"word": "￥300.0元"}], "seat_category": [{"word": "二等座"}],
+		"notice": [{"word": "退票改签时须交回车站"}], "sales_station": [{"word": "改签窗口"}]}}]}`
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+	d := doc.(*Doc)
+	assert.Equal(t, KindNormal, d.Kind)
+	assert.Equal(t, "300.0", d.NetCost)
+}
+
+func TestRefundFeeNotRecognized(t *testing.T) {
+	// 标注中有退票费但金额无法解析时，不能将原票价计入费用
+	response := `{"words_result_num": 1, "words_result": [{"type": "train_ticket", "result": {
+		"date": [{"word": "2024年03月01日"}], "ticket_num": [{"word": "A000011"}],
+		"remarks": [{"word": "退票费报销凭证"}], "ticket_rates": [{"word": "￥300.0元"}]}}]}`
+	doc, err := (&Processor{}).Process([]byte(response))
+	require.NoError(t, err)
+	d := doc.(*Doc)
+	assert.Equal(t, KindRefundFee, d.Kind)
+	assert.Empty(t, d.TicketRates)
+	assert.Contains(t, d.ReviewNote, "退票费")
+
+	Reconcile([]doctype.Document{d})
+	assert.Empty(t, d.NetCost)
+	assert.Equal(t, 0.0, d.netCost())
+
+	docs := &Docs{}
+	docs.Add(d)
+	assert.Contains(t, docs.Sheets()[0].Record(0)["review_note"], "退票费")
+}
@@ -0,0 +1,118 @@
+package runreport
+
+import (
+	"FinDocOCR/dedup"
+	"FinDocOCR/doctype"
+)
+
+// Output 运行报告导出文件名，不含扩展名
+const Output = "运行报告"
+
+// Report 一次运行的处理情况
+type Report struct {
+	RunID string
+	// Processed 识别成功的文件数
+	Processed int
+	// Failed 读取、识别或解析失败的文件数
+	Failed     int
+	Policy     dedup.Policy
+	Duplicates []dedup.Duplicate
+	// Reprocessed 历史运行中处理过、本次重新处理的文件数
+	Reprocessed int
+	// ImageGroups 识别前发现的相同或相似图像，内容相同或足够相似的文件未提交识别
+	ImageGroups []dedup.ImageGroup
+}
+
+var summaryColumns = []doctype.Column{
+	{Key: "item", Header: "项目"},
+	{Key: "value", Header: "值"},
+}
+
+var duplicateColumns = []doctype.Column{
+	{Key: "source_path", Header: "文件"},
+	{Key: "doc_type", Header: "票据类型"},
+	{Key: "key", Header: "票据键"},
+	{Key: "first_run_id", Header: "首次出现的运行"},
+	{Key: "first_source_path", Header: "首次出现的文件"},
+	{Key: "first_seen_at", Header: "首次处理时间"},
+	{Key: "action", Header: "处理方式"},
+}
+
+var imageGroupColumns = []doctype.Column{
+	{Key: "group", Header: "组号", Type: doctype.ColumnNumber},
+	{Key: "source_path", Header: "文件"},
+	{Key: "submitted", Header: "提交识别"},
+	{Key: "distance", Header: "哈希距离", Type: doctype.ColumnNumber},
+}
+
+// imageCounts 返回与其他文件内容相同而未提交识别的图像数、相似而未提交识别的图像数，以及相似但仍提交识别的图像数
+func (r *Report) imageCounts() (identical, skipped, similar int) {
+	for _, group := range r.ImageGroups {
+		for _, member := range group.Members {
+			switch {
+			case member.Identical:
+				identical++
+			case member.Skipped:
+				skipped++
+			default:
+				similar++
+			}
+		}
+	}
+	return identical, skipped, similar
+}
+
+// Sheets 返回运行概览，有重复票据或相似图像时附加对应的明细表
+func (r *Report) Sheets() []doctype.Sheet {
+	action := "标记，不计入行程、抵扣与合规检查"
+	if r.Policy == dedup.PolicySkip {
+		action = "跳过"
+	}
+
+	identical, skipped, similar := r.imageCounts()
+	summary := [][]interface{}{
+		{"运行 ID", r.RunID},
+		{"识别成功", r.Processed},
+		{"处理失败", r.Failed},
+		{"相同文件（未识别）", identical},
+		{"相似图像（未识别）", skipped},
+		{"相似图像（已识别，需复核）", similar},
+		{"重复票据", len(r.Duplicates)},
+		{"重新处理的文件", r.Reprocessed},
+	}
+	sheets := []doctype.Sheet{{Name: "概览", Columns: summaryColumns, Rows: summary}}
+
+	if len(r.Duplicates) > 0 {
+		rows := make([][]interface{}, 0, len(r.Duplicates))
+		for _, d := range r.Duplicates {
+			rows = append(rows, []interface{}{
+				d.SourcePath,
+				string(d.DocType),
+				d.Key,
+				d.FirstRunID,
+				d.FirstSourcePath,
+				d.FirstSeenAt.Format("2006.01.02 15:04"),
+				action,
+			})
+		}
+		sheets = append(sheets, doctype.Sheet{Name: "重复票据", Columns: duplicateColumns, Rows: rows})
+	}
+
+	if len(r.ImageGroups) > 0 {
+		var rows [][]interface{}
+		for i, group := range r.ImageGroups {
+			rows = append(rows, []interface{}{i + 1, group.Representative, "是", 0})
+			for _, member := range group.Members {
+				submitted := "是"
+				if member.Identical {
+					submitted = "否，文件相同"
+				} else if member.Skipped {
+					submitted = "否，图像相似"
+				}
+				rows = append(rows, []interface{}{i + 1, member.SourcePath, submitted, member.Distance})
+			}
+		}
+		sheets = append(sheets, doctype.Sheet{Name: "相似图像", Columns: imageGroupColumns, Rows: rows})
+	}
+	return sheets
+}
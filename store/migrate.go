@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// migrations 按顺序执行的表结构变更，版本号为下标加一。
+// 已发布的迁移不能修改，表结构变更需要追加新的迁移。
+var migrations = []string{
+	// 1: 运行与文档
+	`CREATE TABLE runs (
+		id          TEXT PRIMARY KEY,
+		doc_dir     TEXT NOT NULL,
+		started_at  TEXT NOT NULL,
+		finished_at TEXT
+	);
+	CREATE TABLE documents (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id       TEXT NOT NULL REFERENCES runs(id),
+		source_path  TEXT NOT NULL,
+		source_hash  TEXT NOT NULL,
+		provider     TEXT NOT NULL,
+		doc_type     TEXT NOT NULL,
+		raw_response BLOB,
+		fields       TEXT,
+		error        TEXT NOT NULL DEFAULT '',
+		created_at   TEXT NOT NULL
+	);
+	CREATE INDEX documents_run_id ON documents(run_id);
+	CREATE INDEX documents_source_hash ON documents(source_hash);`,
+
+	// 2: 已处理票据的键，用于跨运行判断重复报销
+	`ALTER TABLE documents ADD COLUMN doc_key TEXT NOT NULL DEFAULT '';
+	ALTER TABLE documents ADD COLUMN duplicate INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE seen_keys (
+		doc_type      TEXT NOT NULL,
+		doc_key       TEXT NOT NULL,
+		document_id   INTEGER NOT NULL REFERENCES documents(id),
+		run_id        TEXT NOT NULL REFERENCES runs(id),
+		source_path   TEXT NOT NULL,
+		first_seen_at TEXT NOT NULL,
+		PRIMARY KEY (doc_type, doc_key)
+	);`,
+}
+
+// migrate 执行尚未应用的迁移，每个迁移在单独的事务中执行
+func (s *Store) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("failed to query schema version: %w", err)
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("store schema version %d is newer than supported version %d", current, len(migrations))
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		if err := s.apply(version); err != nil {
+			return err
+		}
+		logger.Info("Applied store migration ", version)
+	}
+	return nil
+}
+
+func (s *Store) apply(version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(migrations[version-1]); err != nil {
+		return fmt.Errorf("failed to apply migration %d: %w", version, err)
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)",
+		version, time.Now().Format(timeLayout),
+	); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", version, err)
+	}
+	return tx.Commit()
+}
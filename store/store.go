@@ -0,0 +1,295 @@
+package store
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	// 纯 Go 实现的 SQLite 驱动，不依赖 cgo
+	_ "modernc.org/sqlite"
+)
+
+var logger = config.GetLogger()
+
+// timeLayout 数据库中时间的存储格式
+const timeLayout = time.RFC3339
+
+// Store 基于 SQLite 的处理记录存储，保存每次运行及其处理的全部文档
+type Store struct {
+	db *sql.DB
+}
+
+// Run 一次运行
+type Run struct {
+	ID         string
+	DocDir     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Record 一份源文件的处理记录
+type Record struct {
+	ID         int64
+	RunID      string
+	SourcePath string
+	// SourceHash 源文件内容的 SHA-256
+	SourceHash string
+	// Provider 识别服务
+	Provider    string
+	DocType     doctype.DocumentType
+	RawResponse []byte
+	// Document 处理后的文档，保存时序列化为 Fields
+	Document doctype.Document
+	// Fields 处理后文档字段的 JSON
+	Fields json.RawMessage
+	// Key 票据的唯一键，见 doctype.Keyed
+	Key string
+	// Duplicate 是否与此前处理过的票据重复，重复的票据不登记键
+	Duplicate bool
+	// Error 处理失败的原因，成功时为空
+	Error     string
+	CreatedAt time.Time
+}
+
+// SeenKey 票据键首次出现的位置
+type SeenKey struct {
+	DocType    doctype.DocumentType
+	Key        string
+	DocumentID int64
+	RunID      string
+	SourcePath string
+	// SourceHash 首次出现的源文件内容的 SHA-256，用于区分重复报销与重新处理同一文件
+	SourceHash string
+	SeenAt     time.Time
+}
+
+// Open 打开数据库文件并执行未应用的迁移，文件不存在时自动创建
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	// SQLite 同一时间只允许一个写连接
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// StartRun 开始一次运行，运行 ID 由开始时间生成
+func (s *Store) StartRun(docDir string) (*Run, error) {
+	now := time.Now()
+	run := &Run{
+		ID:        now.Format("20060102-150405.000"),
+		DocDir:    docDir,
+		StartedAt: now,
+	}
+
+	_, err := s.db.Exec(
+		"INSERT INTO runs (id, doc_dir, started_at) VALUES (?, ?, ?)",
+		run.ID, run.DocDir, run.StartedAt.Format(timeLayout),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start run: %w", err)
+	}
+
+	logger.Info("Started run ", run.ID)
+	return run, nil
+}
+
+// FinishRun 记录运行的结束时间
+func (s *Store) FinishRun(run *Run) error {
+	run.FinishedAt = time.Now()
+	_, err := s.db.Exec(
+		"UPDATE runs SET finished_at = ? WHERE id = ?",
+		run.FinishedAt.Format(timeLayout), run.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// SaveRecords 在一个事务中保存一次运行的处理记录，并回填记录的 ID
+func (s *Store) SaveRecords(run *Run, records []*Record) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO documents
+		(run_id, source_path, source_hash, provider, doc_type, raw_response, fields, doc_key, duplicate, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	// 同一类型的键只登记首次出现的文档
+	keyStmt, err := tx.Prepare(`INSERT OR IGNORE INTO seen_keys
+		(doc_type, doc_key, document_id, run_id, source_path, first_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer keyStmt.Close()
+
+	now := time.Now()
+	for _, record := range records {
+		record.RunID = run.ID
+		record.CreatedAt = now
+		if record.Document != nil {
+			record.DocType = record.Document.DocumentType()
+			if record.Fields, err = json.Marshal(record.Document); err != nil {
+				return fmt.Errorf("failed to marshal %s: %w", record.SourcePath, err)
+			}
+			if keyed, ok := record.Document.(doctype.Keyed); ok && record.Key == "" {
+				record.Key = keyed.DocumentKey()
+			}
+		}
+
+		result, err := stmt.Exec(
+			record.RunID,
+			record.SourcePath,
+			record.SourceHash,
+			record.Provider,
+			string(record.DocType),
+			record.RawResponse,
+			nullableJSON(record.Fields),
+			record.Key,
+			record.Duplicate,
+			record.Error,
+			record.CreatedAt.Format(timeLayout),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", record.SourcePath, err)
+		}
+		if record.ID, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get id of %s: %w", record.SourcePath, err)
+		}
+
+		if record.Key == "" || record.Duplicate {
+			continue
+		}
+		if _, err := keyStmt.Exec(string(record.DocType), record.Key, record.ID, record.RunID,
+			record.SourcePath, record.CreatedAt.Format(timeLayout)); err != nil {
+			return fmt.Errorf("failed to register key of %s: %w", record.SourcePath, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit records: %w", err)
+	}
+
+	logger.Infof("Saved %d records of run %s", len(records), run.ID)
+	return nil
+}
+
+// Records 按保存顺序返回某次运行的处理记录，读取的记录只包含 Fields 而没有 Document
+func (s *Store) Records(runID string) ([]*Record, error) {
+	rows, err := s.db.Query(`SELECT id, run_id, source_path, source_hash, provider, doc_type,
+		raw_response, fields, doc_key, duplicate, error, created_at FROM documents WHERE run_id = ? ORDER BY id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query records of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var records []*Record
+	for rows.Next() {
+		var (
+			record    Record
+			docType   string
+			fields    sql.NullString
+			createdAt string
+		)
+		if err := rows.Scan(&record.ID, &record.RunID, &record.SourcePath, &record.SourceHash,
+			&record.Provider, &docType, &record.RawResponse, &fields, &record.Key, &record.Duplicate,
+			&record.Error, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %w", err)
+		}
+		record.DocType = doctype.DocumentType(docType)
+		if fields.Valid {
+			record.Fields = json.RawMessage(fields.String)
+		}
+		if record.CreatedAt, err = time.Parse(timeLayout, createdAt); err != nil {
+			return nil, fmt.Errorf("invalid created_at of record %d: %w", record.ID, err)
+		}
+		records = append(records, &record)
+	}
+	return records, rows.Err()
+}
+
+// FindKey 查找票据键首次出现的位置，未出现过时返回 nil
+func (s *Store) FindKey(docType doctype.DocumentType, key string) (*SeenKey, error) {
+	seen := SeenKey{DocType: docType, Key: key}
+	var seenAt string
+	err := s.db.QueryRow(
+		`SELECT k.document_id, k.run_id, k.source_path, COALESCE(d.source_hash, ''), k.first_seen_at
+		FROM seen_keys k LEFT JOIN documents d ON d.id = k.document_id
+		WHERE k.doc_type = ? AND k.doc_key = ?`,
+		string(docType), key,
+	).Scan(&seen.DocumentID, &seen.RunID, &seen.SourcePath, &seen.SourceHash, &seenAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query key %s of %s: %w", key, docType, err)
+	}
+
+	if seen.SeenAt, err = time.Parse(timeLayout, seenAt); err != nil {
+		return nil, fmt.Errorf("invalid first_seen_at of key %s: %w", key, err)
+	}
+	return &seen, nil
+}
+
+// LatestRun 返回最近一次开始的运行，没有运行时返回 nil
+func (s *Store) LatestRun() (*Run, error) {
+	var (
+		run        Run
+		startedAt  string
+		finishedAt sql.NullString
+	)
+	err := s.db.QueryRow(
+		"SELECT id, doc_dir, started_at, finished_at FROM runs ORDER BY started_at DESC, id DESC LIMIT 1",
+	).Scan(&run.ID, &run.DocDir, &startedAt, &finishedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest run: %w", err)
+	}
+
+	if run.StartedAt, err = time.Parse(timeLayout, startedAt); err != nil {
+		return nil, fmt.Errorf("invalid started_at of run %s: %w", run.ID, err)
+	}
+	if finishedAt.Valid {
+		if run.FinishedAt, err = time.Parse(timeLayout, finishedAt.String); err != nil {
+			return nil, fmt.Errorf("invalid finished_at of run %s: %w", run.ID, err)
+		}
+	}
+	return &run, nil
+}
+
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"FinDocOCR/doctype"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDoc struct {
+	DocType   doctype.DocumentType
+	DocNumber string
+	Amount    float64
+}
+
+func (d *testDoc) DocumentType() doctype.DocumentType { return d.DocType }
+func (d *testDoc) AmendData()                         {}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	run, err := s.StartRun("docs")
+	require.NoError(t, err)
+
+	records := []*Record{
+		{
+			SourcePath:  "docs/a.jpg",
+			SourceHash:  "aaa",
+			Provider:    "baidu",
+			RawResponse: []byte(`{"words_result_num":1}`),
+			Document:    &testDoc{DocType: "vat_invoice", DocNumber: "001", Amount: 12.5},
+		},
+		{
+			SourcePath: "docs/b.jpg",
+			SourceHash: "bbb",
+			Provider:   "baidu",
+			Error:      "invalid json data",
+		},
+	}
+	require.NoError(t, s.SaveRecords(run, records))
+	assert.NotZero(t, records[0].ID)
+	require.NoError(t, s.FinishRun(run))
+	require.NoError(t, s.Close())
+
+	// 重新打开时不重复执行迁移
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	latest, err := s.LatestRun()
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, run.ID, latest.ID)
+	assert.False(t, latest.FinishedAt.IsZero())
+
+	saved, err := s.Records(run.ID)
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, doctype.DocumentType("vat_invoice"), saved[0].DocType)
+	assert.JSONEq(t, `{"DocType":"vat_invoice","DocNumber":"001","Amount":12.5}`, string(saved[0].Fields))
+	assert.Equal(t, `{"words_result_num":1}`, string(saved[0].RawResponse))
+	assert.Nil(t, saved[1].Fields)
+	assert.Equal(t, "invalid json data", saved[1].Error)
+}
+
+func TestLatestRunEmpty(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "store.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	run, err := s.LatestRun()
+	require.NoError(t, err)
+	assert.Nil(t, run)
+}
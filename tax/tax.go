@@ -0,0 +1,171 @@
+package tax
+
+import (
+	"FinDocOCR/doctype"
+	"math"
+	"sort"
+	"time"
+)
+
+// Output 进项税抵扣导出文件名，不含扩展名
+const Output = "旅客运输进项税抵扣"
+
+// 旅客运输服务的抵扣类别
+const (
+	CategoryRailway   = "铁路车票"
+	CategoryAir       = "航空运输电子客票行程单"
+	CategoryRoadWater = "公路、水路等其他客票"
+)
+
+// rates 各类别的计算抵扣率：铁路与航空按 9%，公路、水路按 3%
+var rates = map[string]float64{
+	CategoryRailway:   0.09,
+	CategoryAir:       0.09,
+	CategoryRoadWater: 0.03,
+}
+
+// Transport 一张可以计算抵扣进项税的旅客运输票据
+type Transport struct {
+	Category  string
+	Date      time.Time
+	Passenger string
+	// Number 票号或电子客票号
+	Number string
+	// Base 计算抵扣的票面金额，航空运输为票价与燃油附加费之和
+	Base float64
+	// Remark 需要会计人员注意的问题，例如未识别到旅客身份信息
+	Remark string
+}
+
+// TransportSource 可以计算进项税抵扣的文档
+type TransportSource interface {
+	TransportDeduction() (Transport, bool)
+}
+
+// Rate 返回类别的计算抵扣率
+func Rate(category string) (float64, bool) {
+	rate, ok := rates[category]
+	return rate, ok
+}
+
+// Deductible 计算可抵扣的进项税额：票面金额 ÷ (1 + 扣除率) × 扣除率，保留两位小数
+func (t Transport) Deductible() float64 {
+	rate, ok := Rate(t.Category)
+	if !ok {
+		return 0
+	}
+	return round(t.Base / (1 + rate) * rate)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// MonthlySummary 某月某类别的抵扣汇总
+type MonthlySummary struct {
+	Month      string
+	Category   string
+	Count      int
+	Base       float64
+	Deductible float64
+}
+
+// Summarize 按月份与类别汇总，按月份、类别排序
+func Summarize(transports []Transport) []MonthlySummary {
+	byKey := make(map[[2]string]*MonthlySummary)
+	for _, t := range transports {
+		key := [2]string{t.Date.Format("2006-01"), t.Category}
+		summary, exists := byKey[key]
+		if !exists {
+			summary = &MonthlySummary{Month: key[0], Category: key[1]}
+			byKey[key] = summary
+		}
+		summary.Count++
+		summary.Base = round(summary.Base + t.Base)
+		summary.Deductible = round(summary.Deductible + t.Deductible())
+	}
+
+	summaries := make([]MonthlySummary, 0, len(byKey))
+	for _, summary := range byKey {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Month != summaries[j].Month {
+			return summaries[i].Month < summaries[j].Month
+		}
+		return summaries[i].Category < summaries[j].Category
+	})
+	return summaries
+}
+
+// Collection 收集旅客运输票据，导出抵扣明细与月度汇总
+type Collection struct {
+	transports []Transport
+}
+
+func (c *Collection) Add(doc doctype.Document) {
+	source, ok := doc.(TransportSource)
+	if !ok {
+		return
+	}
+	if t, ok := source.TransportDeduction(); ok {
+		c.transports = append(c.transports, t)
+	}
+}
+
+var detailColumns = []doctype.Column{
+	{Key: "date", Header: "日期", Type: doctype.ColumnDate},
+	{Key: "passenger", Header: "旅客"},
+	{Key: "number", Header: "票号"},
+	{Key: "category", Header: "类别"},
+	{Key: "base", Header: "票面金额", Type: doctype.ColumnMoney},
+	{Key: "rate", Header: "扣除率", Type: doctype.ColumnNumber},
+	{Key: "deductible", Header: "可抵扣税额", Type: doctype.ColumnMoney},
+	{Key: "remark", Header: "备注"},
+}
+
+var summaryColumns = []doctype.Column{
+	{Key: "month", Header: "月份"},
+	{Key: "category", Header: "类别"},
+	{Key: "count", Header: "票据张数", Type: doctype.ColumnNumber},
+	{Key: "base", Header: "票面金额合计", Type: doctype.ColumnMoney},
+	{Key: "deductible", Header: "可抵扣税额合计", Type: doctype.ColumnMoney},
+}
+
+// Sheets 返回抵扣明细表与月度汇总表
+func (c *Collection) Sheets() []doctype.Sheet {
+	if len(c.transports) == 0 {
+		return nil
+	}
+
+	details := make([][]interface{}, 0, len(c.transports))
+	for _, t := range c.transports {
+		rate, _ := Rate(t.Category)
+		details = append(details, []interface{}{
+			t.Date.Format("2006.01.02"),
+			t.Passenger,
+			t.Number,
+			t.Category,
+			t.Base,
+			rate,
+			t.Deductible(),
+			t.Remark,
+		})
+	}
+
+	var summaries [][]interface{}
+	for _, summary := range Summarize(c.transports) {
+		summaries = append(summaries, []interface{}{
+			summary.Month,
+			summary.Category,
+			summary.Count,
+			summary.Base,
+			summary.Deductible,
+		})
+	}
+
+	return []doctype.Sheet{
+		{Name: "抵扣明细", Columns: detailColumns, Rows: details},
+		{Name: "月度汇总", Columns: summaryColumns, Rows: summaries},
+	}
+}
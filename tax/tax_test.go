@@ -0,0 +1,45 @@
+package tax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeductible(t *testing.T) {
+	// 铁路车票 553 元：553 ÷ 1.09 × 9% = 45.66
+	railway := Transport{Category: CategoryRailway, Base: 553}
+	assert.Equal(t, 45.66, railway.Deductible())
+
+	// 航空：(票价 1200 + 燃油附加费 60) ÷ 1.09 × 9% = 104.04
+	air := Transport{Category: CategoryAir, Base: 1260}
+	assert.Equal(t, 104.04, air.Deductible())
+
+	// 公路客票 103 元：103 ÷ 1.03 × 3% = 3
+	road := Transport{Category: CategoryRoadWater, Base: 103}
+	assert.Equal(t, 3.0, road.Deductible())
+
+	assert.Equal(t, 0.0, Transport{Category: "未知", Base: 100}.Deductible())
+}
+
+func TestSummarize(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		require.NoError(t, err)
+		return d
+	}
+
+	summaries := Summarize([]Transport{
+		{Category: CategoryRailway, Date: day("2024-03-01"), Base: 553},
+		{Category: CategoryRailway, Date: day("2024-03-05"), Base: 109},
+		{Category: CategoryRoadWater, Date: day("2024-03-10"), Base: 103},
+		{Category: CategoryRailway, Date: day("2024-02-28"), Base: 218},
+	})
+
+	require.Len(t, summaries, 3)
+	assert.Equal(t, MonthlySummary{Month: "2024-02", Category: CategoryRailway, Count: 1, Base: 218, Deductible: 18}, summaries[0])
+	assert.Equal(t, MonthlySummary{Month: "2024-03", Category: CategoryRoadWater, Count: 1, Base: 103, Deductible: 3}, summaries[1])
+	assert.Equal(t, MonthlySummary{Month: "2024-03", Category: CategoryRailway, Count: 2, Base: 662, Deductible: 54.66}, summaries[2])
+}
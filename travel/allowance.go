@@ -0,0 +1,207 @@
+package travel
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"time"
+)
+
+// Rate 某职级在某类城市的每日补助标准
+type Rate struct {
+	// Grade 为空时适用于所有职级
+	Grade     string  `yaml:"grade"`
+	Tier      string  `yaml:"tier"`
+	Meal      float64 `yaml:"meal"`
+	Lodging   float64 `yaml:"lodging"`
+	Transport float64 `yaml:"transport"`
+}
+
+// AllowancePolicy 一个版本的差旅补助政策
+type AllowancePolicy struct {
+	Version       string              `yaml:"version"`
+	EffectiveFrom string              `yaml:"effective_from"`
+	DefaultTier   string              `yaml:"default_tier"`
+	DefaultGrade  string              `yaml:"default_grade"`
+	Cities        map[string][]string `yaml:"cities"`
+	Rates         []Rate              `yaml:"rates"`
+
+	effectiveFrom time.Time
+	tiers         map[string]string
+}
+
+// AllowancePolicies 按生效日期排列的补助政策。行程按出发日期选择当时生效的版本，
+// 因此保留历史版本即可重新生成过去的报表。
+type AllowancePolicies struct {
+	Policies []*AllowancePolicy `yaml:"policies"`
+}
+
+// Allowance 一次行程的补助计算结果
+type Allowance struct {
+	PolicyVersion string
+	Grade         string
+	Tier          string
+	Meal          float64
+	Lodging       float64
+	Transport     float64
+}
+
+// Total 补助合计
+func (a Allowance) Total() float64 {
+	return a.Meal + a.Lodging + a.Transport
+}
+
+// GradeResolver 根据人员姓名返回职级，无法确定时返回空字符串
+type GradeResolver func(passenger string) string
+
+// LoadAllowancePolicies 读取补助政策文件
+func LoadAllowancePolicies(path string) (*AllowancePolicies, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowance policy: %w", err)
+	}
+	return ParseAllowancePolicies(content)
+}
+
+// ParseAllowancePolicies 解析补助政策
+func ParseAllowancePolicies(content []byte) (*AllowancePolicies, error) {
+	var policies AllowancePolicies
+	if err := yaml.Unmarshal(content, &policies); err != nil {
+		return nil, fmt.Errorf("failed to parse allowance policy: %w", err)
+	}
+	if len(policies.Policies) == 0 {
+		return nil, fmt.Errorf("allowance policy file contains no policies")
+	}
+
+	for _, p := range policies.Policies {
+		if p.Version == "" {
+			return nil, fmt.Errorf("allowance policy requires a version")
+		}
+		effectiveFrom, err := time.Parse("2006-01-02", p.EffectiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: invalid effective_from %q", p.Version, p.EffectiveFrom)
+		}
+		p.effectiveFrom = effectiveFrom
+
+		p.tiers = make(map[string]string)
+		for tier, cities := range p.Cities {
+			for _, city := range cities {
+				p.tiers[city] = tier
+			}
+		}
+	}
+
+	sort.Slice(policies.Policies, func(i, j int) bool {
+		return policies.Policies[i].effectiveFrom.Before(policies.Policies[j].effectiveFrom)
+	})
+	return &policies, nil
+}
+
+// PolicyAt 返回指定日期生效的政策，早于所有政策时返回 nil
+func (ps *AllowancePolicies) PolicyAt(date time.Time) *AllowancePolicy {
+	var current *AllowancePolicy
+	for _, p := range ps.Policies {
+		if p.effectiveFrom.After(date) {
+			break
+		}
+		current = p
+	}
+	return current
+}
+
+// TierOf 返回城市类别
+func (p *AllowancePolicy) TierOf(city string) string {
+	if tier, ok := p.tiers[city]; ok {
+		return tier
+	}
+	return p.DefaultTier
+}
+
+// rate 查找职级在某类城市的标准，职级未单独配置时使用不限职级的标准
+func (p *AllowancePolicy) rate(grade, tier string) (Rate, bool) {
+	var general *Rate
+	for i, r := range p.Rates {
+		if r.Tier != tier {
+			continue
+		}
+		if r.Grade == grade {
+			return r, true
+		}
+		if r.Grade == "" {
+			general = &p.Rates[i]
+		}
+	}
+	if general != nil {
+		return *general, true
+	}
+	return Rate{}, false
+}
+
+// stay 在某个城市停留的夜数
+type stay struct {
+	city   string
+	nights int
+}
+
+// stays 计算行程在各目的地停留的夜数：抵达某地到下一段出发之间的天数。
+// 未返回的行程无法确定在最后一个目的地的停留时间，不计入。
+func (t *Trip) stays() []stay {
+	stays := make([]stay, 0, len(t.Legs))
+	for i := 0; i+1 < len(t.Legs); i++ {
+		arrival := truncateDay(t.Legs[i].Arrival)
+		nextDeparture := truncateDay(t.Legs[i+1].Departure)
+		nights := int(nextDeparture.Sub(arrival).Hours() / 24)
+		if nights > 0 {
+			stays = append(stays, stay{city: t.Legs[i].to(), nights: nights})
+		}
+	}
+	return stays
+}
+
+// Calculate 计算行程补助：住宿按各目的地的停留夜数与当地标准累计，
+// 伙食与交通补助按行程天数与主要目的地（停留最久的城市）的标准计算
+func (ps *AllowancePolicies) Calculate(trip *Trip, resolveGrade GradeResolver) (Allowance, error) {
+	policy := ps.PolicyAt(trip.StartDate())
+	if policy == nil {
+		return Allowance{}, fmt.Errorf("no allowance policy effective on %s", trip.StartDate().Format("2006-01-02"))
+	}
+
+	grade := ""
+	if resolveGrade != nil {
+		grade = resolveGrade(trip.Passenger)
+	}
+	if grade == "" {
+		grade = policy.DefaultGrade
+	}
+
+	allowance := Allowance{PolicyVersion: policy.Version, Grade: grade}
+
+	// 主要目的地
+	mainCity := trip.Origin()
+	if destinations := trip.Destinations(); len(destinations) > 0 {
+		mainCity = destinations[0]
+	}
+	longest := 0
+	for _, s := range trip.stays() {
+		tier := policy.TierOf(s.city)
+		r, ok := policy.rate(grade, tier)
+		if !ok {
+			return Allowance{}, fmt.Errorf("policy %s has no rate for grade %s in tier %s", policy.Version, grade, tier)
+		}
+		allowance.Lodging += float64(s.nights) * r.Lodging
+		if s.nights > longest {
+			mainCity, longest = s.city, s.nights
+		}
+	}
+
+	allowance.Tier = policy.TierOf(mainCity)
+	r, ok := policy.rate(grade, allowance.Tier)
+	if !ok {
+		return Allowance{}, fmt.Errorf("policy %s has no rate for grade %s in tier %s", policy.Version, grade, allowance.Tier)
+	}
+	days := float64(trip.Days())
+	allowance.Meal = days * r.Meal
+	allowance.Transport = days * r.Transport
+	return allowance, nil
+}
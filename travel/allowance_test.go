@@ -0,0 +1,86 @@
+package travel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const policyFile = `
+policies:
+  - version: "2024"
+    effective_from: 2024-01-01
+    default_tier: 三类
+    default_grade: 员工
+    cities:
+      一类: [北京, 上海]
+      二类: [武汉, 长沙]
+    rates:
+      - {tier: 一类, meal: 100, lodging: 600, transport: 80}
+      - {tier: 二类, meal: 80, lodging: 450, transport: 60}
+      - {tier: 三类, meal: 60, lodging: 350, transport: 50}
+      - {grade: 经理, tier: 二类, meal: 100, lodging: 550, transport: 80}
+  - version: "2023"
+    effective_from: 2023-01-01
+    default_tier: 三类
+    default_grade: 员工
+    rates:
+      - {tier: 三类, meal: 50, lodging: 300, transport: 40}
+`
+
+func TestAllowanceCalculate(t *testing.T) {
+	policies, err := ParseAllowancePolicies([]byte(policyFile))
+	require.NoError(t, err)
+
+	// 北京 → 武汉（2晚）→ 长沙（2晚）→ 北京，共 5 天
+	trip := &Trip{
+		Passenger: "张三",
+		Returned:  true,
+		Legs: []Leg{
+			leg("张三", ModeTrain, "北京", "武汉", "2024.03.01 08:00", "2024.03.01 12:30", 500),
+			leg("张三", ModeTrain, "武汉", "长沙", "2024.03.03 14:00", "2024.03.03 15:20", 160),
+			leg("张三", ModeAir, "长沙", "北京", "2024.03.05 18:00", "2024.03.05 20:30", 900),
+		},
+	}
+
+	allowance, err := policies.Calculate(trip, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2024", allowance.PolicyVersion)
+	assert.Equal(t, "员工", allowance.Grade)
+	assert.Equal(t, "二类", allowance.Tier)
+	assert.Equal(t, 4*450.0, allowance.Lodging)
+	assert.Equal(t, 5*80.0, allowance.Meal)
+	assert.Equal(t, 5*60.0, allowance.Transport)
+
+	// 职级单独配置的标准
+	allowance, err = policies.Calculate(trip, func(string) string { return "经理" })
+	require.NoError(t, err)
+	assert.Equal(t, 4*550.0, allowance.Lodging)
+	assert.Equal(t, 5*100.0+5*80.0+4*550.0, allowance.Total())
+}
+
+func TestAllowancePolicyVersion(t *testing.T) {
+	policies, err := ParseAllowancePolicies([]byte(policyFile))
+	require.NoError(t, err)
+
+	// 2023 年的行程按当时生效的政策计算
+	trip := &Trip{
+		Passenger: "李四",
+		Returned:  true,
+		Legs: []Leg{
+			leg("李四", ModeTrain, "郑州", "洛阳", "2023.06.01 08:00", "2023.06.01 09:00", 60),
+			leg("李四", ModeTrain, "洛阳", "郑州", "2023.06.02 18:00", "2023.06.02 19:00", 60),
+		},
+	}
+	allowance, err := policies.Calculate(trip, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2023", allowance.PolicyVersion)
+	assert.Equal(t, 300.0, allowance.Lodging)
+	assert.Equal(t, 2*50.0, allowance.Meal)
+
+	// 早于所有政策
+	trip.Legs[0].Departure = trip.Legs[0].Departure.AddDate(-2, 0, 0)
+	_, err = policies.Calculate(trip, nil)
+	assert.Error(t, err)
+}
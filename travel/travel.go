@@ -0,0 +1,312 @@
+package travel
+
+import (
+	"FinDocOCR/config"
+	"FinDocOCR/doctype"
+	"sort"
+	"strings"
+	"time"
+)
+
+var logger = config.GetLogger()
+
+// Output 差旅行程导出文件名，不含扩展名
+const Output = "差旅行程"
+
+// 交通方式
+const (
+	ModeTrain = "火车"
+	ModeAir   = "飞机"
+)
+
+// Leg 一段行程，由车票、机票行程单等交通票据转换而来
+type Leg struct {
+	Passenger string
+	Mode      string
+	// Number 车次或航班号
+	Number string
+	// Departure 出发时间，发车时间未知时只精确到日期
+	Departure       time.Time
+	Arrival         time.Time
+	Origin          string
+	Destination     string
+	OriginCity      string
+	DestinationCity string
+	Fare            float64
+}
+
+// from 返回出发地，优先使用城市以便同城不同车站之间能够衔接
+func (l Leg) from() string {
+	if l.OriginCity != "" {
+		return l.OriginCity
+	}
+	return l.Origin
+}
+
+// to 返回目的地
+func (l Leg) to() string {
+	if l.DestinationCity != "" {
+		return l.DestinationCity
+	}
+	return l.Destination
+}
+
+// LegSource 可以转换为行程段的文档，例如火车票
+type LegSource interface {
+	TravelLeg() (Leg, bool)
+}
+
+// MultiLegSource 可以转换为多个行程段的文档，例如多航段的机票行程单
+type MultiLegSource interface {
+	TravelLegs() []Leg
+}
+
+// Trip 一次出差行程：从出发地出发，经过一个或多个目的地，最终返回（或未返回）
+type Trip struct {
+	Passenger string
+	Legs      []Leg
+	// Returned 最后一段行程是否回到出发地
+	Returned bool
+}
+
+// Origin 行程出发地
+func (t *Trip) Origin() string {
+	return t.Legs[0].from()
+}
+
+// Destinations 行程途经的目的地（不含返回的出发地）
+func (t *Trip) Destinations() []string {
+	destinations := make([]string, 0, len(t.Legs))
+	for _, leg := range t.Legs {
+		to := leg.to()
+		if to == t.Origin() {
+			continue
+		}
+		if len(destinations) == 0 || destinations[len(destinations)-1] != to {
+			destinations = append(destinations, to)
+		}
+	}
+	return destinations
+}
+
+// StartDate 出发日期
+func (t *Trip) StartDate() time.Time {
+	return truncateDay(t.Legs[0].Departure)
+}
+
+// EndDate 最后一段行程的抵达日期
+func (t *Trip) EndDate() time.Time {
+	return truncateDay(t.Legs[len(t.Legs)-1].Arrival)
+}
+
+// Days 行程天数，出发与返回当天均计入
+func (t *Trip) Days() int {
+	return t.Nights() + 1
+}
+
+// Nights 在外过夜天数
+func (t *Trip) Nights() int {
+	return int(t.EndDate().Sub(t.StartDate()).Hours() / 24)
+}
+
+// TotalFare 交通费合计
+func (t *Trip) TotalFare() float64 {
+	total := 0.0
+	for _, leg := range t.Legs {
+		total += leg.Fare
+	}
+	return total
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// BuildTrips 将行程段按乘客、日期与地点的连续性串联为行程：
+// 下一段从上一段的目的地出发且不早于上一段抵达日期时视为同一行程（包括中转），
+// 回到行程出发地或无法衔接时结束当前行程
+func BuildTrips(legs []Leg) []Trip {
+	byPassenger := make(map[string][]Leg)
+	passengers := make([]string, 0)
+	for _, leg := range legs {
+		if _, exists := byPassenger[leg.Passenger]; !exists {
+			passengers = append(passengers, leg.Passenger)
+		}
+		byPassenger[leg.Passenger] = append(byPassenger[leg.Passenger], leg)
+	}
+	sort.Strings(passengers)
+
+	trips := make([]Trip, 0)
+	for _, passenger := range passengers {
+		passengerLegs := byPassenger[passenger]
+		sort.SliceStable(passengerLegs, func(i, j int) bool {
+			return passengerLegs[i].Departure.Before(passengerLegs[j].Departure)
+		})
+
+		var current *Trip
+		for _, leg := range passengerLegs {
+			if current != nil && continues(current, leg) {
+				current.Legs = append(current.Legs, leg)
+			} else {
+				if current != nil {
+					trips = append(trips, *current)
+				}
+				current = &Trip{Passenger: passenger, Legs: []Leg{leg}}
+			}
+
+			if leg.to() == current.Origin() {
+				current.Returned = true
+				trips = append(trips, *current)
+				current = nil
+			}
+		}
+		if current != nil {
+			trips = append(trips, *current)
+		}
+	}
+	return trips
+}
+
+// continues 判断行程段能否接续当前行程
+func continues(trip *Trip, leg Leg) bool {
+	last := trip.Legs[len(trip.Legs)-1]
+	return leg.from() == last.to() && !truncateDay(leg.Departure).Before(truncateDay(last.Arrival))
+}
+
+// Collection 收集所有交通票据的行程段，导出行程汇总表与行程明细表。
+// 设置了 Allowances 时同时计算每次行程的差旅补助。
+type Collection struct {
+	Allowances    *AllowancePolicies
+	GradeResolver GradeResolver
+	legs          []Leg
+}
+
+func (c *Collection) Add(doc doctype.Document) {
+	switch source := doc.(type) {
+	case LegSource:
+		if leg, ok := source.TravelLeg(); ok {
+			c.legs = append(c.legs, leg)
+		}
+	case MultiLegSource:
+		c.legs = append(c.legs, source.TravelLegs()...)
+	}
+}
+
+// Trips 返回串联后的行程
+func (c *Collection) Trips() []Trip {
+	return BuildTrips(c.legs)
+}
+
+// allowanceRow 计算补助并返回补助相关的列，计算失败时记录错误并留空
+func (c *Collection) allowanceRow(trip *Trip) []interface{} {
+	allowance, err := c.Allowances.Calculate(trip, c.GradeResolver)
+	if err != nil {
+		logger.Errorf("Failed to calculate allowance for %s: %v", trip.Passenger, err)
+		return []interface{}{"", "", "", "", "", "", ""}
+	}
+	return []interface{}{
+		allowance.Grade,
+		allowance.Tier,
+		allowance.Meal,
+		allowance.Lodging,
+		allowance.Transport,
+		allowance.Total(),
+		allowance.PolicyVersion,
+	}
+}
+
+var tripColumns = []doctype.Column{
+	{Key: "trip", Header: "行程序号", Type: doctype.ColumnNumber},
+	{Key: "passenger", Header: "人员"},
+	{Key: "start_date", Header: "出发日期", Type: doctype.ColumnDate},
+	{Key: "end_date", Header: "返回日期", Type: doctype.ColumnDate},
+	{Key: "origin", Header: "出发地"},
+	{Key: "destinations", Header: "目的地"},
+	{Key: "legs", Header: "行程段数", Type: doctype.ColumnNumber},
+	{Key: "days", Header: "出差天数", Type: doctype.ColumnNumber},
+	{Key: "nights", Header: "在外过夜天数", Type: doctype.ColumnNumber},
+	{Key: "returned", Header: "是否返回"},
+	{Key: "total_fare", Header: "交通费合计", Type: doctype.ColumnMoney},
+}
+
+// allowanceColumns 设置了补助政策时追加到行程汇总表的列
+var allowanceColumns = []doctype.Column{
+	{Key: "grade", Header: "职级"},
+	{Key: "tier", Header: "城市类别"},
+	{Key: "meal_allowance", Header: "伙食补助", Type: doctype.ColumnMoney},
+	{Key: "lodging_allowance", Header: "住宿补助", Type: doctype.ColumnMoney},
+	{Key: "transport_allowance", Header: "交通补助", Type: doctype.ColumnMoney},
+	{Key: "total_allowance", Header: "补助合计", Type: doctype.ColumnMoney},
+	{Key: "policy_version", Header: "补助政策版本"},
+}
+
+var legColumns = []doctype.Column{
+	{Key: "trip", Header: "行程序号", Type: doctype.ColumnNumber},
+	{Key: "passenger", Header: "人员"},
+	{Key: "mode", Header: "交通方式"},
+	{Key: "number", Header: "车次/航班"},
+	{Key: "departure", Header: "出发时间"},
+	{Key: "origin", Header: "起始地"},
+	{Key: "arrival_date", Header: "抵达日期", Type: doctype.ColumnDate},
+	{Key: "destination", Header: "目的地"},
+	{Key: "fare", Header: "票价", Type: doctype.ColumnMoney},
+}
+
+// Sheets 返回行程汇总表与行程明细表，两表通过行程序号对应
+func (c *Collection) Sheets() []doctype.Sheet {
+	if len(c.legs) == 0 {
+		return nil
+	}
+
+	trips := c.Trips()
+
+	columns := tripColumns
+	if c.Allowances != nil {
+		columns = append(append([]doctype.Column{}, tripColumns...), allowanceColumns...)
+	}
+
+	tripRows := make([][]interface{}, 0, len(trips))
+	var legRows [][]interface{}
+	for i, trip := range trips {
+		returned := "否"
+		if trip.Returned {
+			returned = "是"
+		}
+		row := []interface{}{
+			i + 1,
+			trip.Passenger,
+			trip.StartDate().Format("2006.01.02"),
+			trip.EndDate().Format("2006.01.02"),
+			trip.Origin(),
+			strings.Join(trip.Destinations(), "、"),
+			len(trip.Legs),
+			trip.Days(),
+			trip.Nights(),
+			returned,
+			trip.TotalFare(),
+		}
+		if c.Allowances != nil {
+			row = append(row, c.allowanceRow(&trip)...)
+		}
+		tripRows = append(tripRows, row)
+
+		for _, leg := range trip.Legs {
+			legRows = append(legRows, []interface{}{
+				i + 1,
+				trip.Passenger,
+				leg.Mode,
+				leg.Number,
+				leg.Departure.Format("2006.01.02 15:04"),
+				leg.Origin,
+				leg.Arrival.Format("2006.01.02"),
+				leg.Destination,
+				leg.Fare,
+			})
+		}
+	}
+
+	return []doctype.Sheet{
+		{Name: "行程", Columns: columns, Rows: tripRows},
+		{Name: "行程明细", Columns: legColumns, Rows: legRows},
+	}
+}
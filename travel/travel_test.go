@@ -0,0 +1,82 @@
+package travel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006.01.02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func leg(passenger, mode, from, to, departure, arrival string, fare float64) Leg {
+	return Leg{
+		Passenger:       passenger,
+		Mode:            mode,
+		Departure:       date(departure),
+		Arrival:         date(arrival),
+		Origin:          from + "站",
+		Destination:     to + "站",
+		OriginCity:      from,
+		DestinationCity: to,
+		Fare:            fare,
+	}
+}
+
+func TestBuildTrips(t *testing.T) {
+	legs := []Leg{
+		// 张三：北京 → 武汉（中转）→ 长沙，飞机返回北京
+		leg("张三", ModeAir, "长沙", "北京", "2024.03.05 18:00", "2024.03.05 20:30", 900),
+		leg("张三", ModeTrain, "北京", "武汉", "2024.03.01 08:00", "2024.03.01 12:30", 500),
+		leg("张三", ModeTrain, "武汉", "长沙", "2024.03.01 14:00", "2024.03.01 15:20", 160),
+		// 张三：另一次单程
+		leg("张三", ModeTrain, "北京", "天津", "2024.03.10 09:00", "2024.03.10 09:30", 55),
+		// 李四：当天往返
+		leg("李四", ModeTrain, "上海", "苏州", "2024.03.02 07:00", "2024.03.02 07:30", 40),
+		leg("李四", ModeTrain, "苏州", "上海", "2024.03.02 19:00", "2024.03.02 19:30", 40),
+	}
+
+	trips := BuildTrips(legs)
+	require.Len(t, trips, 3)
+
+	// 乘客按姓名排序，同一乘客的行程按时间排序
+	round := trips[0]
+	assert.Equal(t, "张三", round.Passenger)
+	assert.Len(t, round.Legs, 3)
+	assert.True(t, round.Returned)
+	assert.Equal(t, "北京", round.Origin())
+	assert.Equal(t, []string{"武汉", "长沙"}, round.Destinations())
+	assert.Equal(t, 5, round.Days())
+	assert.Equal(t, 4, round.Nights())
+	assert.Equal(t, 1560.0, round.TotalFare())
+
+	oneWay := trips[1]
+	assert.False(t, oneWay.Returned)
+	assert.Len(t, oneWay.Legs, 1)
+
+	lisi := trips[2]
+	assert.Equal(t, "李四", lisi.Passenger)
+	assert.True(t, lisi.Returned)
+	assert.Equal(t, 1, lisi.Days())
+	assert.Equal(t, 0, lisi.Nights())
+}
+
+func TestBuildTripsBreaksOnDiscontinuity(t *testing.T) {
+	legs := []Leg{
+		leg("王五", ModeTrain, "北京", "上海", "2024.03.01 08:00", "2024.03.01 13:00", 553),
+		// 从杭州出发，与上一段的目的地无法衔接
+		leg("王五", ModeTrain, "杭州", "北京", "2024.03.03 08:00", "2024.03.03 14:00", 600),
+	}
+
+	trips := BuildTrips(legs)
+	require.Len(t, trips, 2)
+	assert.False(t, trips[0].Returned)
+	assert.False(t, trips[1].Returned)
+}
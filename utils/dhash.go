@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anthonynsimon/bild/transform"
+	"image"
+	"image/color"
+	"math/bits"
+)
+
+// DHash 计算图像的差值哈希（dHash）：缩放为 9×8 的灰度图，逐行比较相邻像素的亮度，
+// 左侧更亮时对应位为 1。同一票据的不同照片、扫描件哈希相近，可用汉明距离衡量相似程度。
+func DHash(imageBytes []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return 0, fmt.Errorf("解码图像失败: %v", err)
+	}
+
+	small := transform.Resize(img, 9, 8, transform.Linear)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if luminance(small.RGBAAt(x, y)) > luminance(small.RGBAAt(x+1, y)) {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash, nil
+}
+
+// luminance 按 ITU-R BT.601 计算亮度
+func luminance(c color.RGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+// HammingDistance 返回两个哈希不同的位数
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/anthonynsimon/bild/transform"
+	"image"
+	"image/jpeg"
+)
+
+// DefaultThumbnailSize 缩略图最长边的默认像素数
+const DefaultThumbnailSize = 160
+
+// Thumbnail 将 ImageResize 输出的图像缩小为最长边不超过 maxSize 像素的 JPEG 缩略图，用于嵌入导出的表格
+func Thumbnail(imageBytes []byte, maxSize int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("解码图像失败: %v", err)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			width, height = maxSize, max(1, height*maxSize/width)
+		} else {
+			width, height = max(1, width*maxSize/height), maxSize
+		}
+	}
+	small := transform.Resize(img, width, height, transform.Linear)
+
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, small, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("JPEG编码失败: %v", err)
+	}
+	return buffer.Bytes(), nil
+}
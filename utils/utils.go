@@ -4,7 +4,9 @@ import (
 	"FinDocOCR/config"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/anthonynsimon/bild/imgio"
 	"github.com/anthonynsimon/bild/transform"
@@ -51,6 +53,21 @@ func FindSuffixesInDir(dir string, fileSuffixes []string) ([]string, error) {
 	return filePaths, err
 }
 
+// FileHash 返回文件内容的 SHA-256 十六进制摘要
+func FileHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 const (
 	MaxFileSizeBytes = 4 * 1024 * 1024 // 4MB
 	MinDimension     = 15              // 最短边至少15px
@@ -180,3 +180,56 @@ func isProcessedImageValid(imageData []byte) bool {
 
 	return true
 }
+
+func TestDHash(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// 同一图像的不同尺寸与格式，模拟照片与扫描件
+	createTestImage(t, filepath.Join(tempDir, "photo.jpg"), 800, 600, "jpeg")
+	createTestImage(t, filepath.Join(tempDir, "scan.png"), 1600, 1200, "png")
+
+	photo, err := ImageResize(filepath.Join(tempDir, "photo.jpg"))
+	require.NoError(t, err)
+	scan, err := ImageResize(filepath.Join(tempDir, "scan.png"))
+	require.NoError(t, err)
+
+	photoHash, err := DHash(photo)
+	require.NoError(t, err)
+	scanHash, err := DHash(scan)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, HammingDistance(photoHash, scanHash), 5)
+
+	// 水平翻转后左右亮度关系相反
+	flipped := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			flipped.Set(x, y, color.RGBA{R: uint8(((799 - x) * 255) / 800), G: uint8((y * 255) / 600), B: 100, A: 255})
+		}
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, png.Encode(&buffer, flipped))
+	flippedHash, err := DHash(buffer.Bytes())
+	require.NoError(t, err)
+	assert.Greater(t, HammingDistance(photoHash, flippedHash), 32)
+
+	_, err = DHash([]byte("%PDF-1.4"))
+	assert.Error(t, err)
+}
+
+func TestThumbnail(t *testing.T) {
+	tempDir := t.TempDir()
+	createTestImage(t, filepath.Join(tempDir, "receipt.png"), 600, 1200, "png")
+
+	resized, err := ImageResize(filepath.Join(tempDir, "receipt.png"))
+	require.NoError(t, err)
+	thumbnail, err := Thumbnail(resized, DefaultThumbnailSize)
+	require.NoError(t, err)
+
+	img, err := decodeImage(thumbnail)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultThumbnailSize/2, img.Bounds().Dx())
+	assert.Equal(t, DefaultThumbnailSize, img.Bounds().Dy())
+
+	_, err = Thumbnail([]byte("%PDF-1.4"), DefaultThumbnailSize)
+	assert.Error(t, err)
+}